@@ -1,72 +1,102 @@
 package main
 
 import (
-	"flag"
+	"encoding/json"
 	"fmt"
 	"html/template"
+	"io"
 	"os"
+	"strings"
 
-	"github.com/adamcrosby/aws-cis-scanner/benchmark"
+	"github.com/adamcrosby/aws-cis-scanner/utility/config"
 	"github.com/adamcrosby/aws-cis-scanner/utility/findings"
-	"github.com/adamcrosby/aws-cis-scanner/utility/regions"
 	"github.com/adamcrosby/aws-cis-scanner/utility/report"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/cloudtrail"
-	"github.com/aws/aws-sdk-go/service/cloudwatch"
-	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
-	"github.com/aws/aws-sdk-go/service/configservice"
-	"github.com/aws/aws-sdk-go/service/ec2"
-	"github.com/aws/aws-sdk-go/service/iam"
-	"github.com/aws/aws-sdk-go/service/kms"
-	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/aws/aws-sdk-go/service/sns"
 )
 
-func main() {
-	var regionPtr string
-	const (
-		defaultRegion   = regions.AllRegions
-		regionFlagUsage = "AWS Region in standard shorthand format (eg: 'us-east-1' or 'us-west-2').  Default is \"us-east-1\"."
-	)
-	flag.StringVar(&regionPtr, "region", defaultRegion, regionFlagUsage)
-	flag.StringVar(&regionPtr, "r", defaultRegion, regionFlagUsage+" (shorthand)")
-	flag.Parse()
+const usageText = `Usage: aws-cis-scanner <command> [flags]
 
-	var regionsList []string
+Commands:
+  scan          Run the benchmark checks against the account (the default)
+  list-checks   List every check with its ID, title, whether it is scored and the permissions it needs
+  explain <id>  Show the rationale, audit steps and remediation for a check, eg: explain 3.7
+  report        Render a saved JSON result file as HTML or JSON
+  diff          Compare two saved JSON result files
+  history       Show pass rates over time and how long findings have been open
+  remediate     Plan, apply or roll back the changes that fix the open findings of a saved result file
 
-	switch regionPtr {
-	case regions.CNNorth1:
-		// if region is govcloud or china, special handling:
-		fmt.Println("Isolated Regions (CN) not yet supported.")
-		fmt.Println("If you need this support, please open a github issue.")
-		os.Exit(1)
-	case regions.GovCloud:
-		regionsList = regions.GovRegions
-	case regions.AllRegions:
-		regionsList = regions.CommercialRegions
+Run 'aws-cis-scanner <command> -h' for the flags each command takes.
+`
+
+func main() {
+	// Flags with no command (or no arguments at all) run a scan, as earlier versions did
+	command := "scan"
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "scan":
+		runScan(args)
+	case "list-checks":
+		listChecks(args)
+	case "explain":
+		explainCheck(args)
+	case "report":
+		renderReport(args)
+	case "diff":
+		diffResults(args)
+	case "history":
+		printHistory(args)
+	case "remediate":
+		runRemediate(args)
+	case "help":
+		fmt.Print(usageText)
 	default:
-		// a region was actually specified in the config, so use it.
-		regionsList = []string{regionPtr}
+		fmt.Printf("Unknown command %q\n\n", command)
+		fmt.Print(usageText)
+		os.Exit(1)
 	}
+}
 
-	// Create a new session
-	sess, err := session.NewSession()
+/*
+readScan loads a result file written with the json output format
+*/
+func readScan(filename string) (findings.Scan, error) {
+	var scan findings.Scan
+	f, err := os.Open(filename)
 	if err != nil {
-		panic(err)
+		return scan, err
 	}
-
-	benchmark := make(findings.Checks, findings.FindingsInCISBenchmark)
-
-	for i := range regionsList {
-		conf := aws.Config{Region: aws.String(regionsList[i])}
-		benchmark = checkRegion(benchmark, sess, conf)
+	defer f.Close()
+	if err := json.NewDecoder(f).Decode(&scan); err != nil {
+		return scan, fmt.Errorf("%s: %s", filename, err)
 	}
+	return scan, nil
+}
 
-	printTemplate(benchmark)
+/*
+writeOutput writes the scan in the outputs format to its file, or standard output
+*/
+func writeOutput(o config.Output, scan findings.Scan, data report.Data) error {
+	w := os.Stdout
+	if o.Path != "" && o.Path != "-" {
+		f, err := os.Create(o.Path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if o.Format == config.FormatJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(scan)
+	}
+	return printTemplate(w, data)
 }
 
-func printTemplate(checks findings.Checks) {
+func printTemplate(w io.Writer, data report.Data) error {
 	templateString := report.ReportTemplateHTML
 
 	tmpl := template.New("report template")
@@ -76,31 +106,18 @@ func printTemplate(checks findings.Checks) {
 	if err != nil {
 		panic(err)
 	}
-	err = tmpl.Execute(os.Stdout, checks)
-	if err != nil {
-		panic(err)
-	}
+	return tmpl.Execute(w, data)
 }
 
-func checkRegion(checks findings.Checks, sess *session.Session, conf aws.Config) findings.Checks {
-
-	iamSvc := iam.New(sess, &conf)
-	ctSvc := cloudtrail.New(sess, &conf)
-	s3Svc := s3.New(sess, &conf)
-	cfSvc := configservice.New(sess, &conf)
-	kmsSvc := kms.New(sess, &conf)
-	cwlogsSvc := cloudwatchlogs.New(sess, &conf)
-	cwSvc := cloudwatch.New(sess, &conf)
-	snsSvc := sns.New(sess, &conf)
-	ec2Svc := ec2.New(sess, &conf)
-
-	checks = benchmark.DoIAMChecks(iamSvc, checks)
-
-	// Setup for the Logging section of checks (2.1 - 2.8)
-
-	checks = benchmark.LoggingChecks(kmsSvc, cfSvc, s3Svc, ctSvc, checks)
-	checks = benchmark.MonitoringChecks(snsSvc, cwSvc, cwlogsSvc, ctSvc, checks)
-	checks = benchmark.DoNetworkChecks(ec2Svc, checks)
-
-	return checks
+/*
+splitList splits a comma separated flag value, dropping empty entries
+*/
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
 }
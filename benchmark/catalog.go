@@ -0,0 +1,317 @@
+package benchmark
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Service names, used to decide which SDK clients a selection of checks needs
+const (
+	ServiceIAM            = "iam"
+	ServiceCloudTrail     = "cloudtrail"
+	ServiceS3             = "s3"
+	ServiceConfig         = "config"
+	ServiceKMS            = "kms"
+	ServiceCloudWatchLogs = "logs"
+	ServiceCloudWatch     = "cloudwatch"
+	ServiceSNS            = "sns"
+	ServiceEC2            = "ec2"
+	ServiceAccount        = "account"
+)
+
+// Check describes a single check in the benchmark.  Checks with no services can't be
+// tested through the API and have to be verified by hand.
+type Check struct {
+	ID          string
+	Title       string
+	Scored      bool
+	Services    []string
+	Permissions []string
+	Rationale   string
+	Audit       string
+	Remediation string
+}
+
+var credentialReport = []string{"iam:GenerateCredentialReport", "iam:GetCredentialReport"}
+var passwordPolicy = []string{"iam:GetAccountPasswordPolicy"}
+var monitoringServices = []string{ServiceCloudTrail, ServiceCloudWatchLogs, ServiceCloudWatch, ServiceSNS}
+var monitoringPermissions = []string{"cloudtrail:DescribeTrails", "logs:DescribeMetricFilters", "cloudwatch:DescribeAlarmsForMetric", "sns:ListSubscriptionsByTopic"}
+
+const monitoringRationale = "Real-time monitoring of API calls can be achieved by sending CloudTrail logs to CloudWatch Logs and setting up metric filters and alarms.  %s"
+const monitoringAudit = "Find the CloudWatch Logs group of a multi-region trail, check it has a metric filter with the pattern %s, that an alarm exists on the filter's metric, and that the alarm's SNS topic has at least one subscriber."
+const monitoringRemediation = "Create a metric filter on the trail's log group with the pattern %s, an alarm on its metric (threshold >= 1 over a 5 minute period) and an SNS topic with a subscriber as the alarm action."
+
+// Catalog lists every check in the benchmark, in order
+var Catalog = []Check{
+	{ID: "1.1", Title: Finding1_1Txt, Scored: true, Services: []string{ServiceIAM}, Permissions: credentialReport,
+		Rationale:   "The root account has unrestricted access to every resource in the account.  Using it for everyday tasks makes it far more likely its credentials are exposed or misused.",
+		Audit:       "Download the IAM credential report and check password_last_used, access_key_1_last_used_date and access_key_2_last_used_date for <root_account> are not recent.",
+		Remediation: "Create IAM users or roles with only the permissions needed for day to day work, and keep the root credentials locked away for the few tasks that require them."},
+	{ID: "1.2", Title: Finding1_2Txt, Scored: true, Services: []string{ServiceIAM}, Permissions: credentialReport,
+		Rationale:   "MFA adds a second factor on top of the password, so a stolen or guessed password alone isn't enough to sign in to the console.",
+		Audit:       "In the IAM credential report, every user with password_enabled set to true must have mfa_active set to true.",
+		Remediation: "Have each console user assign a virtual or hardware MFA device under IAM > Users > Security credentials, or remove the console password from users that don't need it."},
+	{ID: "1.3", Title: Finding1_3Txt, Scored: true, Services: []string{ServiceIAM}, Permissions: credentialReport,
+		Rationale:   "Passwords and access keys that are no longer used still grant access.  Disabling them reduces the window for a forgotten credential to be abused.",
+		Audit:       "In the IAM credential report, check that every enabled password and active access key has been used within the last 90 days.",
+		Remediation: "Remove the console password and deactivate or delete the access keys that haven't been used in 90 days."},
+	{ID: "1.4", Title: Finding1_4Txt, Scored: true, Services: []string{ServiceIAM}, Permissions: credentialReport,
+		Rationale:   "Rotating access keys limits how long a leaked key can be used for.",
+		Audit:       "In the IAM credential report, check access_key_1_last_rotated and access_key_2_last_rotated are within the last 90 days for every active key.",
+		Remediation: "Create a second access key, move the application to it, then deactivate and delete the old key."},
+	{ID: "1.5", Title: Finding1_5Txt, Scored: true, Services: []string{ServiceIAM}, Permissions: passwordPolicy,
+		Rationale:   "Password complexity makes passwords harder to guess or brute force.",
+		Audit:       "Check RequireUppercaseCharacters is true in the account password policy.",
+		Remediation: "Set the account password policy to require at least one uppercase letter."},
+	{ID: "1.6", Title: Finding1_6Txt, Scored: true, Services: []string{ServiceIAM}, Permissions: passwordPolicy,
+		Rationale:   "Password complexity makes passwords harder to guess or brute force.",
+		Audit:       "Check RequireLowercaseCharacters is true in the account password policy.",
+		Remediation: "Set the account password policy to require at least one lowercase letter."},
+	{ID: "1.7", Title: Finding1_7Txt, Scored: true, Services: []string{ServiceIAM}, Permissions: passwordPolicy,
+		Rationale:   "Password complexity makes passwords harder to guess or brute force.",
+		Audit:       "Check RequireSymbols is true in the account password policy.",
+		Remediation: "Set the account password policy to require at least one symbol."},
+	{ID: "1.8", Title: Finding1_8Txt, Scored: true, Services: []string{ServiceIAM}, Permissions: passwordPolicy,
+		Rationale:   "Password complexity makes passwords harder to guess or brute force.",
+		Audit:       "Check RequireNumbers is true in the account password policy.",
+		Remediation: "Set the account password policy to require at least one number."},
+	{ID: "1.9", Title: Finding1_9Txt, Scored: true, Services: []string{ServiceIAM}, Permissions: passwordPolicy,
+		Rationale:   "Longer passwords are harder to guess or brute force.",
+		Audit:       "Check MinimumPasswordLength in the account password policy is 14 or greater.",
+		Remediation: "Set the minimum password length in the account password policy to 14 or greater."},
+	{ID: "1.10", Title: Finding1_10Txt, Scored: true, Services: []string{ServiceIAM}, Permissions: passwordPolicy,
+		Rationale:   "Preventing reuse stops users cycling back to a password that may already have been exposed.",
+		Audit:       "Check PasswordReusePrevention is set in the account password policy (the benchmark recommends 24).",
+		Remediation: "Set the account password policy to remember the last 24 passwords."},
+	{ID: "1.11", Title: Finding1_11Txt, Scored: true, Services: []string{ServiceIAM}, Permissions: passwordPolicy,
+		Rationale:   "Expiring passwords limits how long a compromised password can be used for.",
+		Audit:       "Check ExpirePasswords is true and MaxPasswordAge is 90 or less in the account password policy.",
+		Remediation: "Set the account password policy to expire passwords after 90 days or less."},
+	{ID: "1.12", Title: Finding1_12Txt, Scored: true, Services: []string{ServiceIAM}, Permissions: credentialReport,
+		Rationale:   "Root access keys can't be restricted by policy, so a leaked root key exposes the whole account.",
+		Audit:       "In the IAM credential report, check access_key_1_active and access_key_2_active are false for <root_account>.",
+		Remediation: "Sign in as root, and delete the access keys under My Security Credentials."},
+	{ID: "1.13", Title: Finding1_13Txt, Scored: true, Services: []string{ServiceIAM}, Permissions: credentialReport,
+		Rationale:   "MFA on the root account protects the most privileged credentials in the account from password theft.",
+		Audit:       "In the IAM credential report, check mfa_active is true for <root_account>.",
+		Remediation: "Sign in as root, and activate a hardware MFA device under My Security Credentials."},
+	{ID: "1.14", Title: Finding1_14Txt, Scored: false,
+		Rationale:   "Security questions let AWS support verify the owner of the account if the root credentials are lost.",
+		Audit:       "Sign in as root, open My Account and check the Configure Security Challenge Questions section is filled in.",
+		Remediation: "Sign in as root, open My Account and set the security challenge questions.  Store the answers somewhere secure."},
+	{ID: "1.15", Title: Finding1_15Txt, Scored: true, Services: []string{ServiceIAM}, Permissions: append([]string{"iam:ListUserPolicies", "iam:ListAttachedUserPolicies"}, credentialReport...),
+		Rationale:   "Granting permissions through groups and roles keeps access consistent and easy to review as users join, move and leave.",
+		Audit:       "For every IAM user, check ListUserPolicies and ListAttachedUserPolicies return no policies.",
+		Remediation: "Move the user's permissions to a group (or role) and add the user to it, then remove the inline and attached policies from the user."},
+	{ID: "2.1", Title: Finding2_1Txt, Scored: true, Services: []string{ServiceCloudTrail}, Permissions: []string{"cloudtrail:DescribeTrails", "cloudtrail:GetTrailStatus", "cloudtrail:GetEventSelectors"},
+		Rationale:   "A multi-region trail records API activity in every region, including regions you don't normally use, which is where an attacker is least likely to be noticed.",
+		Audit:       "Check at least one trail has IsMultiRegionTrail set to true, GetTrailStatus returns IsLogging true, and its event selectors include management events with ReadWriteType All (or an advanced selector on the Management event category with no other field, such as readOnly or eventSource, narrowing it).  An organization trail from the management account counts for member accounts.",
+		Remediation: "Create a trail, or update an existing one, with --is-multi-region-trail."},
+	{ID: "2.2", Title: Finding2_2Txt, Scored: true, Services: []string{ServiceCloudTrail}, Permissions: []string{"cloudtrail:DescribeTrails"},
+		Rationale:   "Log file validation writes a signed digest file so you can tell whether log files were changed or deleted after CloudTrail delivered them.",
+		Audit:       "Check LogFileValidationEnabled is true for every trail, including trails from other regions and organization trails.",
+		Remediation: "Update each trail with --enable-log-file-validation."},
+	{ID: "2.3", Title: Finding2_3Txt, Scored: true, Services: []string{ServiceCloudTrail, ServiceS3}, Permissions: []string{"cloudtrail:DescribeTrails", "s3:GetBucketLocation", "s3:GetBucketAcl", "s3:GetBucketPolicy", "s3:GetBucketPublicAccessBlock", "s3:GetAccountPublicAccessBlock", "s3:ListAllMyBuckets"},
+		Rationale:   "CloudTrail logs show everything that happens in the account.  Public access to the bucket would let anyone study the account, or find the activity they need to hide.",
+		Audit:       "For each trail's bucket, check the ACL has no grants to AllUsers or AuthenticatedUsers and no bucket policy statement allows \"*\" (or every AWS account) without a condition limiting it to known callers, unless Block Public Access overrides them.",
+		Remediation: "Remove the AllUsers and AuthenticatedUsers grants from the bucket ACL, and remove or restrict bucket policy statements that allow \"*\"."},
+	{ID: "2.4", Title: Finding2_4Txt, Scored: true, Services: []string{ServiceCloudTrail}, Permissions: []string{"cloudtrail:DescribeTrails", "cloudtrail:GetTrailStatus"},
+		Rationale:   "Sending CloudTrail logs to CloudWatch Logs enables real-time analysis and alarms (section 3) on API activity.",
+		Audit:       "Check every trail has a CloudWatchLogsLogGroupArn, and that GetTrailStatus (in the trail's home region) shows LatestCloudWatchLogsDeliveryTime within the last day.",
+		Remediation: "Update the trail with --cloud-watch-logs-log-group-arn and --cloud-watch-logs-role-arn."},
+	{ID: "2.5", Title: Finding2_5Txt, Scored: true, Services: []string{ServiceConfig}, Permissions: []string{"config:DescribeConfigurationRecorders", "config:DescribeConfigurationRecorderStatus", "config:DescribeDeliveryChannels"},
+		Rationale:   "AWS Config records the configuration of resources over time, which is needed for change tracking, incident response and compliance auditing.",
+		Audit:       "In each region, check a configuration recorder exists with AllSupported set to true, DescribeConfigurationRecorderStatus shows it recording with a LastStatus other than FAILURE, and a delivery channel exists.  Check at least one region has IncludeGlobalResourceTypes set to true.",
+		Remediation: "Turn on AWS Config in every region, recording all resources, with a delivery channel to an S3 bucket.  Record global resources in one region."},
+	{ID: "2.6", Title: Finding2_6Txt, Scored: true, Services: []string{ServiceCloudTrail, ServiceS3}, Permissions: []string{"cloudtrail:DescribeTrails", "s3:GetBucketLocation", "s3:GetBucketLogging"},
+		Rationale:   "Access logging on the CloudTrail bucket records who reads or changes the logs themselves.",
+		Audit:       "For every trail, check GetBucketLogging on its bucket returns a LoggingEnabled target.",
+		Remediation: "Enable server access logging on the CloudTrail bucket, to a separate bucket."},
+	{ID: "2.7", Title: Finding2_7Txt, Scored: true, Services: []string{ServiceCloudTrail}, Permissions: []string{"cloudtrail:DescribeTrails"},
+		Rationale:   "Encrypting logs with a KMS key means reading them also requires kms:Decrypt on the key, adding a second control over who can see them.",
+		Audit:       "Check every trail has a KmsKeyId, including trails from other regions and organization trails.",
+		Remediation: "Create a KMS key with a policy allowing CloudTrail to use it, and update each trail with --kms-key-id."},
+	{ID: "2.8", Title: Finding2_8Txt, Scored: true, Services: []string{ServiceKMS}, Permissions: []string{"kms:ListKeys", "kms:ListAliases", "kms:DescribeKey", "kms:GetKeyRotationStatus"},
+		Rationale:   "Rotating key material limits how much data is protected by any one version of a key.",
+		Audit:       "For each enabled, symmetric, customer managed key with KMS generated key material, check GetKeyRotationStatus returns KeyRotationEnabled true.",
+		Remediation: "Enable automatic rotation on each customer created key."},
+	{ID: "3.1", Title: Finding3_1Txt, Scored: true, Services: monitoringServices, Permissions: monitoringPermissions,
+		Rationale:   fmt.Sprintf(monitoringRationale, "Unauthorized API calls can point to a misconfigured application or someone probing what a stolen credential can do."),
+		Audit:       fmt.Sprintf(monitoringAudit, FilterPatterns[0]),
+		Remediation: fmt.Sprintf(monitoringRemediation, FilterPatterns[0])},
+	{ID: "3.2", Title: Finding3_2Txt, Scored: true, Services: monitoringServices, Permissions: monitoringPermissions,
+		Rationale:   fmt.Sprintf(monitoringRationale, "Console logins without MFA bypass the protection check 1.2 puts in place."),
+		Audit:       fmt.Sprintf(monitoringAudit, FilterPatterns[1]),
+		Remediation: fmt.Sprintf(monitoringRemediation, FilterPatterns[1])},
+	{ID: "3.3", Title: Finding3_3Txt, Scored: true, Services: monitoringServices, Permissions: monitoringPermissions,
+		Rationale:   fmt.Sprintf(monitoringRationale, "Root account use should be rare (check 1.1), so every use is worth knowing about."),
+		Audit:       fmt.Sprintf(monitoringAudit, FilterPatterns[2]),
+		Remediation: fmt.Sprintf(monitoringRemediation, FilterPatterns[2])},
+	{ID: "3.4", Title: Finding3_4Txt, Scored: true, Services: monitoringServices, Permissions: monitoringPermissions,
+		Rationale:   fmt.Sprintf(monitoringRationale, "Changes to IAM policies change who can do what in the account."),
+		Audit:       fmt.Sprintf(monitoringAudit, FilterPatterns[3]),
+		Remediation: fmt.Sprintf(monitoringRemediation, FilterPatterns[3])},
+	{ID: "3.5", Title: Finding3_5Txt, Scored: true, Services: monitoringServices, Permissions: monitoringPermissions,
+		Rationale:   fmt.Sprintf(monitoringRationale, "Stopping or changing a trail is a common way to hide later activity."),
+		Audit:       fmt.Sprintf(monitoringAudit, FilterPatterns[4]),
+		Remediation: fmt.Sprintf(monitoringRemediation, FilterPatterns[4])},
+	{ID: "3.6", Title: Finding3_6Txt, Scored: true, Services: monitoringServices, Permissions: monitoringPermissions,
+		Rationale:   fmt.Sprintf(monitoringRationale, "Repeated console login failures can point to a brute force attempt."),
+		Audit:       fmt.Sprintf(monitoringAudit, FilterPatterns[5]),
+		Remediation: fmt.Sprintf(monitoringRemediation, FilterPatterns[5])},
+	{ID: "3.7", Title: Finding3_7Txt, Scored: true, Services: monitoringServices, Permissions: monitoringPermissions,
+		Rationale:   fmt.Sprintf(monitoringRationale, "Data encrypted with a disabled or deleted key can no longer be read."),
+		Audit:       fmt.Sprintf(monitoringAudit, FilterPatterns[6]),
+		Remediation: fmt.Sprintf(monitoringRemediation, FilterPatterns[6])},
+	{ID: "3.8", Title: Finding3_8Txt, Scored: true, Services: monitoringServices, Permissions: monitoringPermissions,
+		Rationale:   fmt.Sprintf(monitoringRationale, "Bucket policy changes can expose data, or lock out the people who need it."),
+		Audit:       fmt.Sprintf(monitoringAudit, FilterPatterns[7]),
+		Remediation: fmt.Sprintf(monitoringRemediation, FilterPatterns[7])},
+	{ID: "3.9", Title: Finding3_9Txt, Scored: true, Services: monitoringServices, Permissions: monitoringPermissions,
+		Rationale:   fmt.Sprintf(monitoringRationale, "Changes to AWS Config can stop configuration from being recorded (check 2.5)."),
+		Audit:       fmt.Sprintf(monitoringAudit, FilterPatterns[8]),
+		Remediation: fmt.Sprintf(monitoringRemediation, FilterPatterns[8])},
+	{ID: "3.10", Title: Finding3_10Txt, Scored: true, Services: monitoringServices, Permissions: monitoringPermissions,
+		Rationale:   fmt.Sprintf(monitoringRationale, "Security group changes can open resources to the network unexpectedly."),
+		Audit:       fmt.Sprintf(monitoringAudit, FilterPatterns[9]),
+		Remediation: fmt.Sprintf(monitoringRemediation, FilterPatterns[9])},
+	{ID: "3.11", Title: Finding3_11Txt, Scored: true, Services: monitoringServices, Permissions: monitoringPermissions,
+		Rationale:   fmt.Sprintf(monitoringRationale, "NACL changes can open subnets to the network unexpectedly."),
+		Audit:       fmt.Sprintf(monitoringAudit, FilterPatterns[10]),
+		Remediation: fmt.Sprintf(monitoringRemediation, FilterPatterns[10])},
+	{ID: "3.12", Title: Finding3_12Txt, Scored: true, Services: monitoringServices, Permissions: monitoringPermissions,
+		Rationale:   fmt.Sprintf(monitoringRationale, "Network gateways are the way traffic gets in and out of a VPC."),
+		Audit:       fmt.Sprintf(monitoringAudit, FilterPatterns[11]),
+		Remediation: fmt.Sprintf(monitoringRemediation, FilterPatterns[11])},
+	{ID: "3.13", Title: Finding3_13Txt, Scored: true, Services: monitoringServices, Permissions: monitoringPermissions,
+		Rationale:   fmt.Sprintf(monitoringRationale, "Route table changes can send traffic somewhere it shouldn't go."),
+		Audit:       fmt.Sprintf(monitoringAudit, FilterPatterns[12]),
+		Remediation: fmt.Sprintf(monitoringRemediation, FilterPatterns[12])},
+	{ID: "3.14", Title: Finding3_14Txt, Scored: true, Services: monitoringServices, Permissions: monitoringPermissions,
+		Rationale:   fmt.Sprintf(monitoringRationale, "VPC and peering changes alter which networks can reach each other."),
+		Audit:       fmt.Sprintf(monitoringAudit, FilterPatterns[13]),
+		Remediation: fmt.Sprintf(monitoringRemediation, FilterPatterns[13])},
+	{ID: "3.15", Title: Finding3_15Txt, Scored: true, Services: []string{ServiceAccount}, Permissions: []string{"account:GetAlternateContact", "account:GetContactInformation"},
+		Rationale:   "AWS contacts the account's security contact when it sees abuse or a possible compromise.  Without one, that notice may never reach the right people.",
+		Audit:       "Check the SECURITY alternate contact is set with aws account get-alternate-contact --alternate-contact-type SECURITY, or under My Account > Alternate contacts in the console.",
+		Remediation: "Open My Account > Alternate Contacts and fill in the Security contact, using a shared mailbox rather than one person."},
+	{ID: "3.16", Title: Finding3_16Txt, Scored: false, Services: []string{ServiceSNS}, Permissions: []string{"sns:ListTopics", "sns:ListSubscriptionsByTopic"},
+		Rationale:   "Alarms only help if they reach people who will act on them.  Subscribers that are stale or unknown can also leak information.",
+		Audit:       "For every SNS topic, review the subscriptions and confirm each endpoint is expected.  Email domains and HTTP(S) hosts outside subscriber_domains, and endpoints in accounts that aren't trusted, are flagged.",
+		Remediation: "Remove unexpected subscriptions from each topic."},
+	{ID: "4.1", Title: Finding4_1Txt, Scored: true, Services: []string{ServiceEC2}, Permissions: []string{"ec2:DescribeSecurityGroups", "ec2:GetManagedPrefixListEntries"},
+		Rationale:   "SSH open to the whole internet exposes instances to brute force and exploits from anywhere.",
+		Audit:       "Check no security group has an ingress rule from 0.0.0.0/0, ::/0 or a prefix list containing them that includes port 22.",
+		Remediation: "Remove the rule, or restrict its source to the addresses that need access (eg: a VPN or bastion range)."},
+	{ID: "4.2", Title: Finding4_2Txt, Scored: true, Services: []string{ServiceEC2}, Permissions: []string{"ec2:DescribeSecurityGroups", "ec2:GetManagedPrefixListEntries"},
+		Rationale:   "RDP open to the whole internet exposes instances to brute force and exploits from anywhere.",
+		Audit:       "Check no security group has an ingress rule from 0.0.0.0/0, ::/0 or a prefix list containing them that includes port 3389.",
+		Remediation: "Remove the rule, or restrict its source to the addresses that need access (eg: a VPN or bastion range)."},
+	{ID: "4.3", Title: Finding4_3Txt, Scored: true, Services: []string{ServiceEC2}, Permissions: []string{"ec2:DescribeFlowLogs", "ec2:DescribeVpcs"},
+		Rationale:   "Flow logs record the traffic reaching a VPC, which is needed to spot unusual traffic and to investigate incidents.",
+		Audit:       "Check each VPC has a flow log with status ACTIVE, capturing REJECT or ALL traffic.",
+		Remediation: "Create a flow log for each VPC, recording REJECT (or ALL) traffic to CloudWatch Logs or S3."},
+	{ID: "4.4", Title: Finding4_4Txt, Scored: true, Services: []string{ServiceEC2}, Permissions: []string{"ec2:DescribeSecurityGroups", "ec2:DescribeNetworkInterfaces"},
+		Rationale:   "Resources launched without a security group get the default one.  If it restricts all traffic, nothing is exposed by accident.",
+		Audit:       "Check the default security group of every VPC has no inbound or outbound rules.",
+		Remediation: "Remove every rule from each VPC's default security group, and move resources that depend on them to purpose built groups."},
+
+	// Section 5: Extended checks
+	{ID: "5.1", Title: Finding5_1Txt, Scored: false, Services: []string{ServiceS3}, Permissions: []string{"s3:ListAllMyBuckets", "s3:GetBucketLocation", "s3:GetBucketAcl", "s3:GetBucketPolicy", "s3:GetBucketOwnershipControls", "s3:GetBucketPublicAccessBlock", "s3:GetAccountPublicAccessBlock", "s3:ListAccessPoints", "s3:GetAccessPoint", "s3:GetAccessPointPolicy"},
+		Rationale:   "A bucket is exposed by whichever of its ACL, bucket policy and access point policies grants the most.  Looking at them together, with Block Public Access and object ownership, shows who can actually reach the data.",
+		Audit:       "For every bucket, check no ACL grant, bucket policy or access point policy gives access to anyone, any authenticated AWS user, or an account that isn't trusted, once Block Public Access and object ownership are taken into account.",
+		Remediation: "Turn on Block Public Access for the account, set object ownership to bucket owner enforced, and remove grants and statements for accounts that aren't listed in trusted_accounts."},
+	{ID: "5.2", Title: Finding5_2Txt, Scored: false, Services: []string{ServiceS3}, Permissions: []string{"s3:ListAllMyBuckets", "s3:GetBucketLocation", "s3:GetEncryptionConfiguration"},
+		Rationale:   "Default encryption makes sure every object written to a bucket is encrypted at rest, even if the writer doesn't ask for it.",
+		Audit:       "For every bucket, check GetBucketEncryption has a default rule using SSE-S3 (AES256) or SSE-KMS (aws:kms).",
+		Remediation: "Turn on default encryption for the bucket, with SSE-KMS where access to the key should be controlled separately."},
+	{ID: "5.3", Title: Finding5_3Txt, Scored: false, Services: []string{ServiceS3}, Permissions: []string{"s3:ListAllMyBuckets", "s3:GetBucketLocation", "s3:GetBucketPolicy"},
+		Rationale:   "S3 accepts plain HTTP requests unless a bucket policy refuses them, so data and credentials can cross the network unencrypted.",
+		Audit:       "For every bucket, check the bucket policy has a Deny statement for every principal covering s3:GetObject and s3:PutObject, with the condition Bool aws:SecureTransport false.",
+		Remediation: "Add a statement to the bucket policy denying s3:* to \"*\" when aws:SecureTransport is false."},
+	{ID: "5.4", Title: Finding5_4Txt, Scored: false, Services: []string{ServiceS3}, Permissions: []string{"s3:ListAllMyBuckets", "s3:GetBucketLocation", "s3:GetBucketVersioning"},
+		Rationale:   "Versioning keeps earlier versions of objects, so data that is overwritten or deleted by mistake or by an attacker can be recovered.",
+		Audit:       "For every bucket, check GetBucketVersioning returns a Status of Enabled.",
+		Remediation: "Enable versioning on the bucket, and add a lifecycle rule to expire old versions if storage costs matter."},
+	{ID: "5.5", Title: Finding5_5Txt, Scored: false, Services: []string{ServiceCloudTrail, ServiceS3}, Permissions: []string{"cloudtrail:DescribeTrails", "s3:GetBucketLocation", "s3:GetBucketVersioning"},
+		Rationale:   "With MFA Delete, removing versions of the CloudTrail logs or turning versioning off needs the root user's MFA device, so stolen credentials can't erase the audit trail.",
+		Audit:       "For each trail's bucket, check GetBucketVersioning returns an MFADelete of Enabled.",
+		Remediation: "As the root user, with its MFA device, enable versioning and MFA Delete on the bucket."},
+	{ID: "5.6", Title: Finding5_6Txt, Scored: false, Services: []string{ServiceKMS}, Permissions: []string{"kms:ListKeys", "kms:ListAliases", "kms:DescribeKey", "kms:GetKeyPolicy"},
+		Rationale:   "A key policy open to anyone lets any AWS account use or manage the key, and so decrypt whatever it protects.",
+		Audit:       "For each customer managed key, check no key policy Allow statement has a principal of \"*\" (or an account wildcard) without a condition pinning it to the caller's account, organization or source.",
+		Remediation: "Replace the \"*\" principal with the accounts or roles that need the key, or add a kms:CallerAccount or aws:PrincipalOrgID condition."},
+	{ID: "5.7", Title: Finding5_7Txt, Scored: false, Services: []string{ServiceKMS}, Permissions: []string{"kms:ListKeys", "kms:ListAliases", "kms:DescribeKey", "kms:GetKeyPolicy", "kms:ListGrants"},
+		Rationale:   "Keys shared with other accounts by mistake (eg: the CloudTrail key) let those accounts decrypt the data.  Access should only go to accounts that are expected.",
+		Audit:       "For each customer managed key, check no key policy statement or grant gives access to an account other than the scanned one, unless it is listed in trusted_accounts.",
+		Remediation: "Remove the statement from the key policy, or retire the grant, for accounts that shouldn't have access.  Add expected accounts to trusted_accounts."},
+	{ID: "5.8", Title: Finding5_8Txt, Scored: false, Services: []string{ServiceKMS}, Permissions: []string{"kms:ListKeys", "kms:ListAliases", "kms:DescribeKey", "kms:GetKeyPolicy"},
+		Rationale:   "If the key policy doesn't allow the account root principal, IAM policies can't grant access to the key.  When the principals it does name are deleted the key can't be managed without AWS Support.",
+		Audit:       "For each customer managed key, check a key policy Allow statement names the account (its ID or root ARN) as a principal for kms:PutKeyPolicy, eg: kms:*.",
+		Remediation: "Add the default statement allowing arn:aws:iam::<account-id>:root kms:* on the key, then manage access with IAM policies."},
+}
+
+/*
+Lookup returns the catalog entry for a check ID
+*/
+func Lookup(id string) (Check, bool) {
+	for _, c := range Catalog {
+		if c.ID == id {
+			return c, true
+		}
+	}
+	return Check{}, false
+}
+
+/*
+Manual reports whether a check has to be verified by hand
+*/
+func (c Check) Manual() bool {
+	return len(c.Services) == 0
+}
+
+/*
+Section returns the section of the benchmark a check belongs to, eg: "2" for "2.7"
+*/
+func (c Check) Section() string {
+	return strings.SplitN(c.ID, ".", 2)[0]
+}
+
+/*
+ServiceNeeded reports whether any selected check uses the given service, so clients for
+services no selected check uses are never created
+*/
+func ServiceNeeded(service string) bool {
+	for _, c := range Catalog {
+		if !Enabled(c.ID) {
+			continue
+		}
+		for _, s := range c.Services {
+			if s == service {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/*
+ValidatePatterns returns an error for the first check pattern that doesn't match any check in
+the catalog, so a typo like "4.9" doesn't silently run nothing
+*/
+func ValidatePatterns(patterns []string) error {
+	for _, p := range patterns {
+		found := false
+		for _, c := range Catalog {
+			if ok, _ := path.Match(p, c.ID); ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%q does not match any check", p)
+		}
+	}
+	return nil
+}
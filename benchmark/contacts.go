@@ -0,0 +1,99 @@
+package benchmark
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/adamcrosby/aws-cis-scanner/utility/findings"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/account"
+)
+
+/*
+ContactChecks runs check 3.15: the account must have a security alternate contact, so AWS can
+reach the right people about abuse or a possible compromise.  Contacts are account wide, so it
+only needs to run once.
+*/
+func ContactChecks(accountSvc *account.Account, checks findings.Checks) findings.Checks {
+	if Enabled("3.15") {
+		checks["Finding 3.15"] = ensureSecurityContact(accountSvc)
+	}
+	return checks
+}
+
+/*
+ensureSecurityContact reads the SECURITY alternate contact.  The primary contact is added for
+context where it can be read, as it's who AWS falls back to, but it doesn't pass the check.
+*/
+func ensureSecurityContact(accountSvc *account.Account) findings.Finding {
+	f := findings.Finding{
+		Name:        "Finding 3.15",
+		Description: Finding3_15Txt,
+		Status:      findings.Status{Checked: true, Open: findings.FindingOpen},
+		Notes:       make(map[string]string)}
+
+	resp, err := accountSvc.GetAlternateContact(&account.GetAlternateContactInput{AlternateContactType: aws.String(account.AlternateContactTypeSecurity)})
+	switch {
+	case err == nil:
+		c := resp.AlternateContact
+		evidence := fmt.Sprintf("Security contact: %s", contactText(aws.StringValue(c.Name), aws.StringValue(c.EmailAddress), aws.StringValue(c.PhoneNumber)))
+		if title := aws.StringValue(c.Title); title != "" {
+			evidence += ", " + title
+		}
+		f.Status.Open = findings.FindingClosed
+		f.Notes["User"] = evidence
+		f.AddResource("", Account, findings.FindingClosed, evidence)
+		return f
+	case awsErrorCode(err) == account.ErrCodeResourceNotFoundException:
+		evidence := "No security alternate contact is registered"
+		if primary, err := primaryContact(accountSvc); err == nil {
+			evidence += ", so AWS security notices only go to the primary contact (" + primary + ")"
+		}
+		f.Notes["User"] = evidence
+		f.AddResource("", Account, findings.FindingOpen, evidence)
+		return f
+	}
+
+	f.Status.Open = findings.FindingUnk
+	var evidence string
+	if awsErrorCode(err) == account.ErrCodeAccessDeniedException {
+		evidence = "Couldn't read the security contact: the scanner needs account:GetAlternateContact"
+	} else {
+		evidence = "Couldn't read the security contact: " + errorText(err)
+	}
+	primary, primaryErr := primaryContact(accountSvc)
+	switch {
+	case primaryErr == nil:
+		evidence += ".  Primary contact: " + primary
+	case awsErrorCode(primaryErr) == account.ErrCodeAccessDeniedException:
+		evidence += ".  Reading the primary contact needs account:GetContactInformation"
+	}
+	f.Notes["User"] = evidence
+	f.AddResource("", Account, findings.FindingUnk, evidence)
+	return f
+}
+
+// primaryContact returns the account's primary contact, eg: "Jane Doe (Example Corp), +1 555 0100"
+func primaryContact(accountSvc *account.Account) (string, error) {
+	resp, err := accountSvc.GetContactInformation(&account.GetContactInformationInput{})
+	if err != nil {
+		return "", err
+	}
+	c := resp.ContactInformation
+	name := aws.StringValue(c.FullName)
+	if company := aws.StringValue(c.CompanyName); company != "" {
+		name += " (" + company + ")"
+	}
+	return contactText(name, "", aws.StringValue(c.PhoneNumber)), nil
+}
+
+// contactText joins the parts of a contact that are set, eg: "Security Team, security@example.com"
+func contactText(parts ...string) string {
+	var set []string
+	for _, p := range parts {
+		if p != "" {
+			set = append(set, p)
+		}
+	}
+	return strings.Join(set, ", ")
+}
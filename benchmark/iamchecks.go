@@ -2,6 +2,7 @@ package benchmark
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/adamcrosby/aws-cis-scanner/utility/accounts"
 	"github.com/adamcrosby/aws-cis-scanner/utility/findings"
@@ -14,25 +15,52 @@ import (
 const credentialReportTrue = "true"
 const credentialReportFalse = "false"
 const rootAccountName = "<root_account>"
-const days30 = 30 * 24
-const days90 = 90 * 24
 const days1 = 24
-const finding1_11Val = 90
-const finding1_9Val = 14
 
 /*
 DoIAMChecks runs the checks for section 1 of the CIS Benchmark and returns a single findings map
 */
 func DoIAMChecks(iamSvc *iam.IAM, checks findings.Checks) findings.Checks {
 
-	a := accounts.GetAccounts(iamSvc)
-	pp := accounts.GetPasswordPolicy(iamSvc)
+	// Only fetch the credential report and password policy if a check that needs them was selected
+	var a []accounts.Account
+	if anyEnabled("1.1", "1.2", "1.3", "1.4", "1.12", "1.13", "1.15") {
+		a = accounts.GetAccounts(iamSvc)
+	}
 
-	checks["Finding 1.1"] = avoidRootAccountUse(a)
-	checks["Finding 1.2"] = iamMFAEnabled(a)
-	checks["Finding 1.3"] = areCredentialsDisabledAfter90Days(a)
-	checks["Finding 1.4"] = areCredentialsRotatedWithin90Days(a)
+	if Enabled("1.1") {
+		checks["Finding 1.1"] = avoidRootAccountUse(a)
+	}
+	if Enabled("1.2") {
+		checks["Finding 1.2"] = iamMFAEnabled(a)
+	}
+	if Enabled("1.3") {
+		checks["Finding 1.3"] = areCredentialsDisabledAfter90Days(a)
+	}
+	if Enabled("1.4") {
+		checks["Finding 1.4"] = areCredentialsRotatedWithin90Days(a)
+	}
+
+	if anyEnabled("1.5", "1.6", "1.7", "1.8", "1.9", "1.10", "1.11") {
+		checks = passwordPolicyChecks(accounts.GetPasswordPolicy(iamSvc), checks)
+	}
+	if Enabled("1.12") {
+		checks["Finding 1.12"] = ensureNoRootAccessKey(a)
+	}
+	if Enabled("1.13") {
+		checks["Finding 1.13"] = ensureRootAccountMFAEnabled(a)
+	}
+	if Enabled("1.15") {
+		checks["Finding 1.15"] = iamPoliciesOnlyOnGroupsOrRoles(a, iamSvc)
+	}
+	return checks
+}
 
+/*
+passwordPolicyChecks runs checks 1.5 - 1.11 against the account password policy.  With no
+policy set at all, every one of them fails.
+*/
+func passwordPolicyChecks(pp iam.PasswordPolicy, checks findings.Checks) findings.Checks {
 	if pp != (iam.PasswordPolicy{}) {
 
 		checks["Finding 1.5"] = findings.Finding{
@@ -128,18 +156,32 @@ func DoIAMChecks(iamSvc *iam.IAM, checks findings.Checks) findings.Checks {
 				Checked: true,
 				Open:    findings.FindingOpen}}
 	}
-	checks["Finding 1.12"] = ensureNoRootAccessKey(a)
-	checks["Finding 1.13"] = ensureRootAccountMFAEnabled(a)
-
-	checks["Finding 1.15"] = findings.Finding{
-		Name:        "Finding 1.15",
-		Description: Finding1_15Txt,
-		Status: findings.Status{
-			Checked: true,
-			Open:    accounts.UserPoliciesExist(a, iamSvc)}}
+
+	// Only keep the password policy findings that were selected
+	for _, id := range []string{"1.5", "1.6", "1.7", "1.8", "1.9", "1.10", "1.11"} {
+		if !Enabled(id) {
+			delete(checks, "Finding "+id)
+		}
+	}
 	return checks
 }
 
+/*
+Check 1.15 - ensure IAM policies are attached only to groups or roles
+*/
+func iamPoliciesOnlyOnGroupsOrRoles(a []accounts.Account, iamSvc *iam.IAM) findings.Finding {
+	resp := findings.Finding{Name: "Finding 1.15", Description: Finding1_15Txt, Status: findings.Status{Checked: true, Open: findings.FindingClosed}, Notes: make(map[string]string)}
+	users := accounts.UsersWithPolicies(a, iamSvc)
+	for i := range users {
+		resp.Status.Open = findings.FindingOpen
+		resp.AddResource("", users[i], findings.FindingOpen, "User has inline or managed policies attached")
+	}
+	if len(users) > 0 {
+		resp.Notes["User"] = fmt.Sprintf("Users with policies attached: %s", strings.Join(users, ", "))
+	}
+	return resp
+}
+
 /*
 Check 1.5 - # of upper case characters
 */
@@ -185,7 +227,7 @@ func passPolicyNumber(pp iam.PasswordPolicy) string {
 Check 1.9 - minimum password length
 */
 func passPolicyMinLength(pp iam.PasswordPolicy) string {
-	if *pp.MinimumPasswordLength >= finding1_9Val {
+	if *pp.MinimumPasswordLength >= int64(Settings.Thresholds.PasswordMinLength) {
 		return findings.FindingClosed
 	}
 	return findings.FindingOpen
@@ -208,7 +250,7 @@ func passPolicyPreventReuse(pp iam.PasswordPolicy) string {
 Check 1.11 - max password age
 */
 func passPolicyMaxAge(pp iam.PasswordPolicy) string {
-	if *pp.ExpirePasswords && (*pp.MaxPasswordAge > finding1_11Val) {
+	if *pp.ExpirePasswords && pp.MaxPasswordAge != nil && *pp.MaxPasswordAge <= int64(Settings.Thresholds.PasswordMaxAgeDays) {
 		return findings.FindingClosed
 	}
 	return findings.FindingOpen
@@ -219,7 +261,8 @@ Check 1.2 - Ensure MFA is enabled for all iam users with passwords
 */
 func iamMFAEnabled(a []accounts.Account) findings.Finding {
 	// Iterate over each account in the list
-	resp := findings.Finding{Name: "Finding 1.2", Description: Finding1_2Txt, Status: findings.Status{Checked: true}, Notes: make(map[string]string)}
+	resp := findings.Finding{Name: "Finding 1.2", Description: Finding1_2Txt, Status: findings.Status{Checked: true, Open: findings.FindingClosed}, Notes: make(map[string]string)}
+	var users []string
 
 	for i := range a {
 		//fmt.Printf("Processing username: %s\n", a[i]["user"])
@@ -236,14 +279,15 @@ func iamMFAEnabled(a []accounts.Account) findings.Finding {
 			if a[i]["password_enabled"] == credentialReportTrue && a[i]["mfa_active"] == credentialReportFalse {
 				//fmt.Printf("User: %s has password but no MFA", a[i]["user"])
 				resp.Status.Open = findings.FindingOpen
-				resp.Notes["User"] = fmt.Sprintf("Account %s has password but no MFA", a[i]["user"])
-			} else {
-				//fmt.Printf("User: %s has no password, or has password and MFA\n", a[i]["user"])
-				resp.Status.Open = findings.FindingClosed
+				users = append(users, a[i]["user"])
+				resp.AddResource("", a[i]["user"], findings.FindingOpen, "Console password enabled but no MFA device active")
 			}
 		}
 
 	}
+	if len(users) > 0 {
+		resp.Notes["User"] = fmt.Sprintf("Users with a password but no MFA: %s", strings.Join(users, ", "))
+	}
 	return resp
 }
 
@@ -263,6 +307,7 @@ func ensureNoRootAccessKey(a []accounts.Account) findings.Finding {
 				// resp is false because this check FAILS if either of these conditions are true
 				resp.Notes["User"] = fmt.Sprintf("Root Account has an active access key.")
 				resp.Status.Open = findings.FindingOpen
+				resp.AddResource("", rootAccountName, findings.FindingOpen, "Root account has an active access key")
 			} else {
 				// Root does not have an active access key
 				//fmt.Println("Root account does not have an active access key")
@@ -293,6 +338,7 @@ func ensureRootAccountMFAEnabled(a []accounts.Account) findings.Finding {
 				// Root does not have an MFA token, check fails
 				resp.Status.Open = findings.FindingOpen
 				resp.Notes["User"] = "Root user does not have an MFA token associated."
+				resp.AddResource("", rootAccountName, findings.FindingOpen, "No MFA device active")
 			}
 		} else {
 			// skip to next user if not <root_user> here
@@ -304,7 +350,7 @@ func ensureRootAccountMFAEnabled(a []accounts.Account) findings.Finding {
 
 /*
 Check 1.1 avoid use of root accounts
-'Avoid' isn't defined, so just check to see if you've used root in last 30 days)
+'Avoid' isn't defined, so just check to see if you've used root in last 30 days (or Thresholds.RootUseDays))
 */
 func avoidRootAccountUse(a []accounts.Account) findings.Finding {
 	resp := findings.Finding{Name: "Finding 1.1", Description: Finding1_1Txt, Status: findings.Status{Checked: true}, Notes: make(map[string]string)}
@@ -312,13 +358,15 @@ func avoidRootAccountUse(a []accounts.Account) findings.Finding {
 	for i := range a {
 		// only check <root_user> here
 		if a[i]["user"] == rootAccountName {
-			if isActiveInDays(a[i]["access_key_1_last_used_date"], days30) ||
-				isActiveInDays(a[i]["access_key_2_last_used_date"], days30) ||
-				isActiveInDays(a[i]["password_last_used"], days30) {
+			days := Settings.Thresholds.RootUseDays
+			if isActiveInDays(a[i]["access_key_1_last_used_date"], hours(days)) ||
+				isActiveInDays(a[i]["access_key_2_last_used_date"], hours(days)) ||
+				isActiveInDays(a[i]["password_last_used"], hours(days)) {
 				// If any of the 3 access methods have been used in the last month, fail the check
 
 				resp.Status.Open = findings.FindingOpen
-				resp.Notes["User"] = "Root account or it's access keys used in last 30 days."
+				resp.Notes["User"] = fmt.Sprintf("Root account or it's access keys used in last %d days.", days)
+				resp.AddResource("", rootAccountName, findings.FindingOpen, fmt.Sprintf("Password or access keys used in the last %d days", days))
 			} else {
 				// None of the methods have been used in last 30 days, check passes
 
@@ -336,29 +384,33 @@ func avoidRootAccountUse(a []accounts.Account) findings.Finding {
 func areCredentialsDisabledAfter90Days(a []accounts.Account) findings.Finding {
 	overallresp := findings.Finding{Name: "Finding 1.3", Description: Finding1_3Txt, Status: findings.Status{Checked: true}, Notes: make(map[string]string)}
 	overallresp.Status.Open = findings.FindingClosed // Default to closed, as absence == pass for this check
+	days := Settings.Thresholds.CredentialUnusedDays
 	for i := range a {
 		var resp = true
 		if a[i]["access_key_1_active"] == credentialReportTrue {
-			if !isActiveInDays(a[i]["access_key_1_last_used_date"], days90) {
+			if !isActiveInDays(a[i]["access_key_1_last_used_date"], hours(days)) {
 				// credential hasn't been used within 90  days but is enabled
 				// so fail the check
 				resp = false
-				overallresp.Notes["User"] = fmt.Sprintf("Account %s Access Key 1 unused in 90 days: last used on %s", a[i]["user"], a[i]["access_key_1_last_used_date"])
+				overallresp.Notes["User"] = fmt.Sprintf("Account %s Access Key 1 unused in %d days: last used on %s", a[i]["user"], days, a[i]["access_key_1_last_used_date"])
+				overallresp.AddResource("", a[i]["user"]+"/access_key_1", findings.FindingOpen, "Last used on "+a[i]["access_key_1_last_used_date"])
 			}
 		}
 		if a[i]["access_key_2_active"] == credentialReportTrue {
-			if !isActiveInDays(a[i]["access_key_2_last_used_date"], days90) {
+			if !isActiveInDays(a[i]["access_key_2_last_used_date"], hours(days)) {
 				// credential hasn't been used within 90  days but is enabled
 				// so fail the check
 				resp = false
-				overallresp.Notes["User"] = fmt.Sprintf("Account %s Access Key 2 unused in 90 days: last used on %s", a[i]["user"], a[i]["access_key_2_last_used_date"])
+				overallresp.Notes["User"] = fmt.Sprintf("Account %s Access Key 2 unused in %d days: last used on %s", a[i]["user"], days, a[i]["access_key_2_last_used_date"])
+				overallresp.AddResource("", a[i]["user"]+"/access_key_2", findings.FindingOpen, "Last used on "+a[i]["access_key_2_last_used_date"])
 			}
 		}
 		if a[i]["password_enabled"] == credentialReportTrue {
-			if !isActiveInDays(a[i]["password_last_used"], days90) {
+			if !isActiveInDays(a[i]["password_last_used"], hours(days)) {
 				// credential hasn't been used within 90  days but is enabled
 				// so fail the check
-				overallresp.Notes["User"] = fmt.Sprintf("Account %s has password, but unused in 90 days: last used on %s", a[i]["user"], a[i]["password_last_used"])
+				overallresp.Notes["User"] = fmt.Sprintf("Account %s has password, but unused in %d days: last used on %s", a[i]["user"], days, a[i]["password_last_used"])
+				overallresp.AddResource("", a[i]["user"]+"/password", findings.FindingOpen, "Last used on "+a[i]["password_last_used"])
 				resp = false
 			}
 		}
@@ -374,21 +426,24 @@ func areCredentialsDisabledAfter90Days(a []accounts.Account) findings.Finding {
 func areCredentialsRotatedWithin90Days(a []accounts.Account) findings.Finding {
 	overallresp := findings.Finding{Name: "Finding 1.4", Description: Finding1_4Txt, Status: findings.Status{Checked: true}, Notes: make(map[string]string)}
 	overallresp.Status.Open = findings.FindingClosed // Default to closed, as absence == pass for this check
+	days := Settings.Thresholds.AccessKeyRotationDays
 	for i := range a {
 		var resp = true
 		if a[i]["access_key_1_active"] == credentialReportTrue {
-			if !isActiveInDays(a[i]["access_key_1_last_rotated"], days90) {
+			if !isActiveInDays(a[i]["access_key_1_last_rotated"], hours(days)) {
 				// credential hasn't been used within 90  days but is enabled
 				// so fail the check
-				overallresp.Notes["User"] = fmt.Sprintf("Account %s Access Key 1 active, but older than 90 days: last rotated on %s", a[i]["user"], a[i]["access_key_1_last_rotated"])
+				overallresp.Notes["User"] = fmt.Sprintf("Account %s Access Key 1 active, but older than %d days: last rotated on %s", a[i]["user"], days, a[i]["access_key_1_last_rotated"])
+				overallresp.AddResource("", a[i]["user"]+"/access_key_1", findings.FindingOpen, "Last rotated on "+a[i]["access_key_1_last_rotated"])
 				resp = false
 			}
 		}
 		if a[i]["access_key_2_active"] == credentialReportTrue {
-			if !isActiveInDays(a[i]["access_key_2_last_rotated"], days90) {
+			if !isActiveInDays(a[i]["access_key_2_last_rotated"], hours(days)) {
 				// credential hasn't been used within 90  days but is enabled
 				// so fail the check
-				overallresp.Notes["User"] = fmt.Sprintf("Account %s Access Key 2 active, but older than 90 days: last rotated on %s", a[i]["user"], a[i]["access_key_2_last_rotated"])
+				overallresp.Notes["User"] = fmt.Sprintf("Account %s Access Key 2 active, but older than %d days: last rotated on %s", a[i]["user"], days, a[i]["access_key_2_last_rotated"])
+				overallresp.AddResource("", a[i]["user"]+"/access_key_2", findings.FindingOpen, "Last rotated on "+a[i]["access_key_2_last_rotated"])
 				resp = false
 			}
 		}
@@ -64,3 +64,15 @@ const (
 	Finding4_3Txt = "Ensure VPC Flow Logging is Enabled in all Applicable Regions (Scored)"
 	Finding4_4Txt = "Ensure the default security group restricts all traffic (Scored)"
 )
+
+// Section 5: Extended checks, beyond the CIS benchmark
+const (
+	Finding5_1Txt = "Ensure no S3 bucket is publicly or cross-account accessible (Extended)"
+	Finding5_2Txt = "Ensure all S3 buckets have default encryption enabled (Extended)"
+	Finding5_3Txt = "Ensure all S3 bucket policies deny requests without TLS (Extended)"
+	Finding5_4Txt = "Ensure versioning is enabled on all S3 buckets (Extended)"
+	Finding5_5Txt = "Ensure MFA Delete is enabled on the CloudTrail S3 buckets (Extended)"
+	Finding5_6Txt = "Ensure no KMS key policy allows access to anyone (Extended)"
+	Finding5_7Txt = "Ensure KMS keys are not shared with untrusted accounts (Extended)"
+	Finding5_8Txt = "Ensure every KMS key policy lets the account manage the key (Extended)"
+)
@@ -0,0 +1,246 @@
+package benchmark
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/adamcrosby/aws-cis-scanner/utility/findings"
+	"github.com/adamcrosby/aws-cis-scanner/utility/policy"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kms"
+)
+
+// kmsKey is a customer managed key, or a key that couldn't be described (Err is set)
+type kmsKey struct {
+	ID      string
+	Aliases []string
+	Meta    *kms.KeyMetadata
+	Err     error
+}
+
+// name returns the key's aliases, or its ID if it has none
+func (k kmsKey) name() string {
+	if len(k.Aliases) > 0 {
+		return strings.Join(k.Aliases, ", ")
+	}
+	return k.ID
+}
+
+// aliased returns the key's aliases to add to evidence, eg: " (alias/app)"
+func (k kmsKey) aliased() string {
+	if len(k.Aliases) > 0 {
+		return " (" + strings.Join(k.Aliases, ", ") + ")"
+	}
+	return ""
+}
+
+// Keys reads the customer managed keys of a region once, for both the rotation check (2.8) and
+// the key policy checks (5.6 - 5.8)
+type Keys struct {
+	svc    *kms.KMS
+	keys   []kmsKey
+	err    error
+	listed bool
+}
+
+/*
+NewKeys returns the customer managed keys of the client's region, listed the first time they're needed
+*/
+func NewKeys(kmsSvc *kms.KMS) *Keys {
+	return &Keys{svc: kmsSvc}
+}
+
+// customer returns the region's customer managed keys, listing them on the first call
+func (k *Keys) customer() ([]kmsKey, error) {
+	if !k.listed {
+		k.keys, k.err = customerKeys(k.svc)
+		k.listed = true
+	}
+	return k.keys, k.err
+}
+
+/*
+customerKeys returns every customer managed key in the client's region, with its aliases.  AWS
+managed keys are left out.  Keys that can't be described are returned with the error, as they may
+be customer managed.
+*/
+func customerKeys(kmsSvc *kms.KMS) ([]kmsKey, error) {
+	var entries []*kms.KeyListEntry
+	err := kmsSvc.ListKeysPages(&kms.ListKeysInput{}, func(page *kms.ListKeysOutput, last bool) bool {
+		entries = append(entries, page.Keys...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Aliases only make the report easier to read, so the keys are still checked without them
+	aliases := make(map[string][]string)
+	kmsSvc.ListAliasesPages(&kms.ListAliasesInput{}, func(page *kms.ListAliasesOutput, last bool) bool {
+		for _, a := range page.Aliases {
+			if a.TargetKeyId != nil {
+				aliases[*a.TargetKeyId] = append(aliases[*a.TargetKeyId], aws.StringValue(a.AliasName))
+			}
+		}
+		return true
+	})
+
+	var keys []kmsKey
+	for _, e := range entries {
+		k := kmsKey{ID: aws.StringValue(e.KeyId), Aliases: aliases[aws.StringValue(e.KeyId)]}
+		key, err := kmsSvc.DescribeKey(&kms.DescribeKeyInput{KeyId: e.KeyId})
+		if err != nil {
+			k.Err = err
+		} else if aws.StringValue(key.KeyMetadata.KeyManager) != kms.KeyManagerTypeCustomer {
+			continue
+		} else {
+			k.Meta = key.KeyMetadata
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+/*
+KMSChecks runs the Section 5 checks of customer managed key policies and grants in a region: keys
+open to anyone (5.6), keys shared with accounts that aren't trusted (5.7) and keys the account can
+no longer manage through IAM (5.8).  Keys pending deletion are left out.
+*/
+func KMSChecks(kmsKeys *Keys, checks findings.Checks) findings.Checks {
+	if !anyEnabled("5.6", "5.7", "5.8") {
+		return checks
+	}
+	kmsSvc := kmsKeys.svc
+	region := aws.StringValue(kmsSvc.Config.Region)
+	public := findings.Finding{Name: "Finding 5.6", Description: Finding5_6Txt, Status: findings.Status{Checked: true, Open: findings.FindingClosed}, Notes: make(map[string]string)}
+	shared := findings.Finding{Name: "Finding 5.7", Description: Finding5_7Txt, Status: findings.Status{Checked: true, Open: findings.FindingClosed}, Notes: make(map[string]string)}
+	managed := findings.Finding{Name: "Finding 5.8", Description: Finding5_8Txt, Status: findings.Status{Checked: true, Open: findings.FindingClosed}, Notes: make(map[string]string)}
+	results := []*findings.Finding{&public, &shared, &managed}
+	failing := make([][]string, len(results))
+
+	// add records a key's result for one of the checks
+	add := func(i int, k kmsKey, status, evidence string) {
+		f := results[i]
+		f.AddResource(region, k.ID, status, evidence+k.aliased())
+		switch status {
+		case findings.FindingOpen:
+			f.Status.Open = findings.FindingOpen
+			failing[i] = append(failing[i], k.name()+" ("+region+")")
+		case findings.FindingUnk:
+			f.Status.Open = worseStatus(f.Status.Open, findings.FindingUnk)
+		}
+	}
+
+	keys, err := kmsKeys.customer()
+	if err != nil {
+		for _, f := range results {
+			f.Status.Open = findings.FindingUnk
+			f.Notes["User"] = fmt.Sprintf("Couldn't list keys in %s: %s", region, errorText(err))
+		}
+	}
+	for _, k := range keys {
+		if k.Err != nil {
+			for i := range results {
+				add(i, k, findings.FindingUnk, "Couldn't describe the key: "+errorText(k.Err))
+			}
+			continue
+		}
+		switch aws.StringValue(k.Meta.KeyState) {
+		case kms.KeyStatePendingDeletion, kms.KeyStatePendingReplicaDeletion:
+			continue
+		}
+
+		var doc *policy.Document
+		resp, err := kmsSvc.GetKeyPolicy(&kms.GetKeyPolicyInput{KeyId: aws.String(k.ID), PolicyName: aws.String("default")})
+		if err == nil {
+			doc, err = policy.Parse(aws.StringValue(resp.Policy))
+		}
+		if err != nil {
+			for i := range results {
+				add(i, k, findings.FindingUnk, "Couldn't read the key policy: "+errorText(err))
+			}
+			continue
+		}
+
+		if exposures := doc.Exposures(); len(exposures) > 0 {
+			var open []string
+			for _, e := range exposures {
+				open = append(open, "key policy "+e.String())
+			}
+			add(0, k, findings.FindingOpen, strings.Join(open, "; "))
+		} else {
+			add(0, k, findings.FindingClosed, "Not public")
+		}
+
+		if Enabled("5.7") {
+			status, evidence := keySharing(kmsSvc, k, doc)
+			add(1, k, status, evidence)
+		}
+
+		if sid, ok := doc.AllowsAccount(Account, "kms:PutKeyPolicy"); !ok {
+			add(2, k, findings.FindingOpen, fmt.Sprintf("No key policy statement allows the account (arn:aws:iam::%s:root) to manage the key, so IAM policies can't grant access to it", Account))
+		} else if sid != "" {
+			add(2, k, findings.FindingClosed, fmt.Sprintf("Statement %q lets the account manage the key", sid))
+		} else {
+			add(2, k, findings.FindingClosed, "The key policy lets the account manage the key")
+		}
+	}
+
+	for i, id := range []string{"5.6", "5.7", "5.8"} {
+		if !Enabled(id) {
+			continue
+		}
+		if err == nil {
+			results[i].Notes["User"] = strings.Join(failing[i], ", ")
+		}
+		checks[results[i].Name] = findings.Merge(checks[results[i].Name], *results[i])
+	}
+	return checks
+}
+
+/*
+keySharing is the result for 5.7: whether a key's policy or grants give access to accounts that
+aren't trusted
+*/
+func keySharing(kmsSvc *kms.KMS, k kmsKey, doc *policy.Document) (string, string) {
+	var untrusted, trusted []string
+	for _, e := range doc.CrossAccount(Account) {
+		if trustedAccount(strings.TrimPrefix(e.Who, "account ")) {
+			trusted = append(trusted, "key policy "+e.String())
+		} else {
+			untrusted = append(untrusted, "key policy "+e.String())
+		}
+	}
+	err := kmsSvc.ListGrantsPages(&kms.ListGrantsInput{KeyId: aws.String(k.ID)}, func(page *kms.ListGrantsResponse, last bool) bool {
+		for _, g := range page.Grants {
+			grantee := aws.StringValue(g.GranteePrincipal)
+			other := policy.AccountOf(grantee)
+			if other == "" || other == Account {
+				continue
+			}
+			grant := fmt.Sprintf("grant %s allows %s to %s", grantName(g), strings.Join(aws.StringValueSlice(g.Operations), ", "), grantee)
+			if trustedAccount(other) {
+				trusted = append(trusted, grant)
+			} else {
+				untrusted = append(untrusted, grant)
+			}
+		}
+		return true
+	})
+	switch {
+	case len(untrusted) > 0:
+		return findings.FindingOpen, "Cross-account: " + strings.Join(untrusted, "; ")
+	case err != nil:
+		return findings.FindingUnk, "Couldn't list grants: " + errorText(err)
+	case len(trusted) > 0:
+		return findings.FindingClosed, "Shared with trusted accounts: " + strings.Join(trusted, "; ")
+	}
+	return findings.FindingClosed, "Not shared with other accounts"
+}
+
+// grantName returns a grant's name, or its ID if it has none
+func grantName(g *kms.GrantListEntry) string {
+	if name := aws.StringValue(g.Name); name != "" {
+		return fmt.Sprintf("%q", name)
+	}
+	return aws.StringValue(g.GrantId)
+}
@@ -1,8 +1,8 @@
 package benchmark
 
 import (
-	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/adamcrosby/aws-cis-scanner/utility/findings"
 	"github.com/aws/aws-sdk-go/aws"
@@ -10,6 +10,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/configservice"
 	"github.com/aws/aws-sdk-go/service/kms"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3control"
 )
 
 // AuthenticedUsersURI is the AWS Bucket Policy URI for all Authenticated users
@@ -19,260 +20,248 @@ const AuthenticedUsersURI = "http://acs.amazonaws.com/groups/global/Authenticate
 const AllUsersURI = "http://acs.amazonaws.com/groups/global/AllUsers"
 
 /*
-S3ACL for JSON decoding of S3 ACLs */
-type S3ACL struct {
-	Version   string
-	Statement []S3ACLentry
-}
-
-/*
-S3ACLentry for JSON decoding of S3 ACLs */
-type S3ACLentry struct {
-	Effect string
-	//Principal S3ACLPrincipal
-	Principal string
-}
-
-/*
-LoggingChecks checks if cloudtrails are configured properly on this region
+LoggingChecks checks if cloudtrails are configured properly on this region.  Trails are added to
+the scan's trails as they're listed, and the trail checks (including MFA Delete on the trail
+buckets, 5.5) cover every trail seen so far.
 */
-func LoggingChecks(kmsSvc *kms.KMS, configSvc *configservice.ConfigService, s3Svc *s3.S3, ct *cloudtrail.CloudTrail, checks findings.Checks) findings.Checks {
+func LoggingChecks(kmsKeys *Keys, configSvc *configservice.ConfigService, s3Svc *s3.S3, s3ctl *s3control.S3Control, ct *cloudtrail.CloudTrail, trails *Trails, checks findings.Checks) findings.Checks {
 
-	params := &cloudtrail.DescribeTrailsInput{
-		IncludeShadowTrails: aws.Bool(true),
-		TrailNameList:       []*string{},
+	// Only look up the trails if a check that uses them was selected: clients for services
+	// no selected check uses are nil.  A region whose trails can't be listed is recorded on trails,
+	// and the trail checks report it as unknown.
+	if anyEnabled("2.1", "2.2", "2.3", "2.4", "2.6", "2.7", "5.5") {
+		trails.add(ct)
 	}
-	trails, err := ct.DescribeTrails(params)
-
-	if err != nil {
-		panic(err)
+	// Trail buckets can be in any region, so they are read through clients for their own regions
+	if anyEnabled("2.3", "2.6", "5.5") {
+		trails.bucketsFor(s3Svc, s3ctl)
+	}
+	if Enabled("2.1") {
+		checks["Finding 2.1"] = trails.unread(multiRegionEnabled(trails))
+	}
+	if Enabled("2.2") {
+		checks["Finding 2.2"] = trails.unread(trails.finding("Finding 2.2", Finding2_2Txt, logValidation))
+	}
+	if Enabled("2.3") {
+		checks["Finding 2.3"] = trails.unread(ensureS3LogsBucketNotPublic(trails))
+	}
+	if Enabled("2.4") {
+		checks["Finding 2.4"] = trails.unread(trails.finding("Finding 2.4", Finding2_4Txt, trails.cloudWatchDelivery))
+	}
+	if Enabled("2.5") {
+		checks["Finding 2.5"] = mergeConfigRegions(checks["Finding 2.5"], ensureConfigEnabled(configSvc))
+	}
+	if Enabled("2.6") {
+		checks["Finding 2.6"] = trails.unread(trails.finding("Finding 2.6", Finding2_6Txt, trails.bucketLogging))
+	}
+	if Enabled("2.7") {
+		checks["Finding 2.7"] = trails.unread(trails.finding("Finding 2.7", Finding2_7Txt, logEncryption))
+	}
+	if Enabled("2.8") {
+		checks["Finding 2.8"] = findings.Merge(checks["Finding 2.8"], ensureCMKRotationEnabled(kmsKeys))
+	}
+	if Enabled("5.5") {
+		checks["Finding 5.5"] = trails.unread(trailBucketsMFADelete(trails))
 	}
-	checks["Finding 2.1"] = multiRegionEnabled(trails.TrailList)
-	checks["Finding 2.2"] = logValidationEnabled(trails.TrailList)
-	checks["Finding 2.3"] = ensureS3LogsBucketNotPublic(trails.TrailList, s3Svc)
-	checks["Finding 2.4"] = cloudWatchIntegration(trails.TrailList, ct)
-	checks["Finding 2.5"] = ensureConfigEnabled(configSvc)
-	checks["Finding 2.6"] = ensureBucketLoggingEnabled(trails.TrailList, s3Svc)
-	checks["Finding 2.7"] = ensureLogsEncrypted(trails.TrailList)
-	checks["Finding 2.8"] = ensureCMKRotationEnabled(kmsSvc)
 
 	return checks
 }
 
-func multiRegionEnabled(trails []*cloudtrail.Trail) findings.Finding {
-	resp := findings.Finding{Name: "Finding 2.1", Description: Finding2_1Txt, Status: findings.Status{Checked: true, Open: findings.FindingOpen}, Notes: make(map[string]string)}
-	for i := range trails {
-		if *trails[i].IsMultiRegionTrail {
-			resp.Status.Open = findings.FindingClosed
-		} else {
-			resp.Notes["User"] = "No cloud trail is multi-region"
-		}
-	}
-	return resp
+/*
+ensureS3LogsBucketNotPublic fails for each trail bucket that anyone, or any authenticated AWS user,
+can reach through its ACL or bucket policy, once Block Public Access is taken into account
+*/
+func ensureS3LogsBucketNotPublic(t *Trails) findings.Finding {
+	return bucketFinding("Finding 2.3", Finding2_3Txt, trailBuckets(t.list), nil, func(bucket string) (string, string, string) {
+		r := t.result("Finding 2.3", bucket, func() findings.Resource {
+			region, status, evidence := t.buckets.exposure(bucket)
+			return findings.Resource{Region: region, Open: status, Evidence: evidence}
+		})
+		return r.Region, r.Open, r.Evidence
+	})
 }
 
-func logValidationEnabled(trails []*cloudtrail.Trail) findings.Finding {
-	resp := findings.Finding{Name: "Finding 2.2", Description: Finding2_2Txt, Status: findings.Status{Checked: true, Open: findings.FindingOpen}, Notes: make(map[string]string)}
-	for i := range trails {
-		if *trails[i].LogFileValidationEnabled {
-			resp.Status.Open = findings.FindingClosed
-		} else {
-			// have to reset if ANY trail is false the check fails
-			resp.Status.Open = findings.FindingOpen
-			resp.Notes["User"] = fmt.Sprintf("%s does not have Log file validation enabled.", *trails[i].Name)
-		}
-	}
-	return resp
+/*
+trailBucketsMFADelete is 5.5 for the buckets of every trail seen so far, each read once
+*/
+func trailBucketsMFADelete(t *Trails) findings.Finding {
+	return bucketFinding("Finding 5.5", Finding5_5Txt, trailBuckets(t.list), nil, func(bucket string) (string, string, string) {
+		r := t.result("Finding 5.5", bucket, func() findings.Resource {
+			region, status, evidence := t.buckets.mfaDelete(bucket)
+			return findings.Resource{Region: region, Open: status, Evidence: evidence}
+		})
+		return r.Region, r.Open, r.Evidence
+	})
 }
 
-func cloudWatchIntegration(trails []*cloudtrail.Trail, ct *cloudtrail.CloudTrail) findings.Finding {
-	resp := findings.Finding{Name: "Finding 2.4", Description: Finding2_4Txt, Status: findings.Status{Checked: true, Open: findings.FindingOpen}, Notes: make(map[string]string)}
-
-	var trailARN *string
-	for i := range trails {
-		// CloudWatchLogsLogGroupArn may be nil, so reference the pointer here instead
-		// of dereferencing to the value
-		if trails[i].CloudWatchLogsLogGroupArn != nil {
-			// CloudWatchLogsLogGroupArn isn't nil, so it exists, so dereference here
-			trailARN = trails[i].TrailARN
-		}
-	}
-	// at least one of the CloudWatchLogsLogGroupArn settings was configured, so now
-	// we need to check that Trail to make sure events have been delivered in the last day
-	// or else fail the check
-	if trailARN != nil {
-		params := &cloudtrail.GetTrailStatusInput{Name: trailARN}
-		trailstatus, err := ct.GetTrailStatus(params)
-		if err != nil {
-			panic(err)
-		}
-		// Ensure LatestCloudWatchLogsDeliveryTime is less than 1 day ago to pass checek
-		if isActiveInLastDay(trailstatus.LatestCloudWatchLogsDeliveryTime) {
-			resp.Status.Open = findings.FindingClosed
-		} else {
-			resp.Status.Open = findings.FindingOpen
-			resp.Notes["User"] = fmt.Sprintf("%s does not have a delivery time in the last day", *trailARN)
-		}
+/*
+ensureConfigEnabled reports the AWS Config recorder of one region.  The region passes when its
+recorder records all resource types, is recording with a healthy last status, and has a delivery
+channel.  Regions recording global resources are listed in the GlobalResources note, so
+mergeConfigRegions can require one across the scan.
+*/
+func ensureConfigEnabled(cs *configservice.ConfigService) findings.Finding {
+	resp := findings.Finding{Name: "Finding 2.5", Description: Finding2_5Txt, Status: findings.Status{Checked: true, Open: findings.FindingClosed}, Notes: make(map[string]string)}
+	region := aws.StringValue(cs.Config.Region)
 
+	status, evidence, global := configRecorder(cs)
+	resp.AddResource(region, region, status, evidence)
+	resp.Status.Open = status
+	if global {
+		resp.Notes["GlobalResources"] = region
 	}
 	return resp
 }
 
-/*
-* Finding 2.7 - Ensures log files are encrypted with KMS in cloud trail
- */
-func ensureLogsEncrypted(trails []*cloudtrail.Trail) findings.Finding {
-	resp := findings.Finding{Name: "Finding 2.7", Description: Finding2_7Txt, Status: findings.Status{Checked: true, Open: findings.FindingOpen}, Notes: make(map[string]string)}
-	for i := range trails {
-		if trails[i].KmsKeyId != nil {
-			// Ensure struct member is present
-			if *trails[i].KmsKeyId != "" {
-				resp.Status.Open = findings.FindingClosed
-			}
-		} else {
-			// have to reset if ANY trail is false the check fails
-			resp.Status.Open = findings.FindingOpen
-		}
+// configRecorder returns the status and evidence of a region's recorder, and whether it records
+// global resources
+func configRecorder(cs *configservice.ConfigService) (string, string, bool) {
+	recorders, err := cs.DescribeConfigurationRecorders(&configservice.DescribeConfigurationRecordersInput{})
+	if err != nil {
+		return findings.FindingUnk, "Couldn't read the configuration recorders: " + errorText(err), false
 	}
-	return resp
-}
-
-func ensureS3LogsBucketNotPublic(trails []*cloudtrail.Trail, s3Svc *s3.S3) findings.Finding {
-	// Default to finding Closed, only override if we find permissions: absence of perms == pass (default ACL is deny)
-	resp := findings.Finding{Name: "Finding 2.3", Description: Finding2_3Txt, Status: findings.Status{Checked: true, Open: findings.FindingClosed}}
-
-	acls := true
-	for i := range trails {
-		if trails[i].S3BucketName != nil {
-			/* S3 Bucket ACL checks
-			 */
-			acls = s3BucketACLChecks(s3Svc, *trails[i].S3BucketName)
-			/* S3 Buck Policy Checks
-			 */
-			acls = s3BucketPolicyChecks(s3Svc, *trails[i].S3BucketName)
-		}
+	if len(recorders.ConfigurationRecorders) == 0 {
+		return findings.FindingOpen, "No configuration recorder", false
 	}
-	if !acls {
-		// Only change to Open if we found something
-		resp.Status.Open = findings.FindingOpen
+	// A region has at most one recorder
+	recorder := recorders.ConfigurationRecorders[0]
+	allSupported, global := false, false
+	if g := recorder.RecordingGroup; g != nil {
+		allSupported = aws.BoolValue(g.AllSupported)
+		global = aws.BoolValue(g.IncludeGlobalResourceTypes)
 	}
-	return resp
-}
 
-func s3BucketACLChecks(s3Svc *s3.S3, s3BucketName string) bool {
-	resp := true
-	aclParams := &s3.GetBucketAclInput{
-		Bucket: aws.String(s3BucketName), // Required
-	}
-	acl, err := s3Svc.GetBucketAcl(aclParams)
+	statuses, err := cs.DescribeConfigurationRecorderStatus(&configservice.DescribeConfigurationRecorderStatusInput{ConfigurationRecorderNames: []*string{recorder.Name}})
 	if err != nil {
-		fmt.Println(err.Error())
+		return findings.FindingUnk, "Couldn't read the recorder status: " + errorText(err), global
 	}
-	for grant := range acl.Grants {
-		// ensure struct member exists (URI is only set when ID is not!)
-		if acl.Grants[grant].Grantee.URI != nil {
-			if *acl.Grants[grant].Grantee.URI == AllUsersURI {
-				// All users has been granted permissiosn to the bucket
-				resp = false
-				fmt.Println("Failing check due to AllUsers having: ", *acl.Grants[grant].Permission)
-			}
-			if *acl.Grants[grant].Grantee.URI == AuthenticedUsersURI {
-				// Auth'd users has been granted perms to Bucket
-				resp = false
-				fmt.Println("Failing check due to Auth'd users having: ", *acl.Grants[grant].Permission)
-			}
+	// The SDK spells the last status "Failure", the API and CLI "FAILURE", so compare either way
+	recording, failed, lastStatus := false, false, "none"
+	for _, st := range statuses.ConfigurationRecordersStatus {
+		recording = aws.BoolValue(st.Recording)
+		if st.LastStatus != nil {
+			lastStatus = aws.StringValue(st.LastStatus)
+		}
+		failed = strings.EqualFold(lastStatus, configservice.RecorderStatusFailure)
+		if failed && st.LastErrorCode != nil {
+			lastStatus += " (" + aws.StringValue(st.LastErrorCode) + ")"
 		}
 	}
 
-	return resp
-}
-func s3BucketPolicyChecks(s3Svc *s3.S3, s3BucketName string) bool {
-	resp := true
-
-	policyParams := &s3.GetBucketPolicyInput{
-		Bucket: aws.String(s3BucketName), // Required
-	}
-	policy, err := s3Svc.GetBucketPolicy(policyParams)
+	channels, err := cs.DescribeDeliveryChannels(&configservice.DescribeDeliveryChannelsInput{})
 	if err != nil {
-		fmt.Println(err.Error())
+		return findings.FindingUnk, "Couldn't read the delivery channels: " + errorText(err), global
+	}
+	channel := "none"
+	for _, c := range channels.DeliveryChannels {
+		channel = aws.StringValue(c.Name) + " to " + aws.StringValue(c.S3BucketName)
 	}
 
-	// Decode JSON - note we throw away almost all of it here
-	// and only keep the 3 fields we need
-	var m S3ACL
-	b := []byte(*policy.Policy) // Unmarshal requires byte array
-	_ = json.Unmarshal(b, &m)
-
-	for idx := range m.Statement {
-		// ensure struct member exists
-		if &m.Statement[idx].Principal != nil {
-			// Check to make sure there no 'Allow' to '*' policy statement
-			if m.Statement[idx].Principal == "*" && m.Statement[idx].Effect == "Allow" {
-				fmt.Println("Failing because of bucket policy for *")
-				resp = false
-			}
-		}
+	evidence := fmt.Sprintf("Recorder %s: recording %s; last status %s; all resource types %s; global resources %s; delivery channel %s",
+		aws.StringValue(recorder.Name), findings.YesNo(recording), lastStatus, findings.YesNo(allSupported), findings.YesNo(global), channel)
+	if !recording || failed || !allSupported || len(channels.DeliveryChannels) == 0 {
+		return findings.FindingOpen, evidence, global
 	}
-	return resp
+	return findings.FindingClosed, evidence, global
 }
 
-func ensureBucketLoggingEnabled(trails []*cloudtrail.Trail, s3Svc *s3.S3) findings.Finding {
-	resp := findings.Finding{Name: "Finding 2.6", Description: Finding2_6Txt, Status: findings.Status{Checked: true, Open: findings.FindingOpen}, Notes: make(map[string]string)}
-	for i := range trails {
-		if trails[i].S3BucketName != nil {
-			params := &s3.GetBucketLoggingInput{
-				Bucket: aws.String(*trails[i].S3BucketName), // Required
-			}
-			loggingStatus, err := s3Svc.GetBucketLogging(params)
-			if err != nil {
-				panic(err)
-			}
-			if loggingStatus.LoggingEnabled != nil {
-				resp.Status.Open = findings.FindingClosed
-			}
+/*
+mergeConfigRegions adds a region's result for 2.5 to the regions scanned so far.  The check fails
+for each region that fails, and if no region records global resources (eg: IAM).
+*/
+func mergeConfigRegions(prev, next findings.Finding) findings.Finding {
+	merged := findings.Merge(prev, next)
+	merged.Status.Open = findings.FindingClosed
+	var failing []string
+	unknown := false
+	for _, r := range merged.Resources {
+		switch r.Open {
+		case findings.FindingOpen:
+			merged.Status.Open = findings.FindingOpen
+			failing = append(failing, r.Region)
+		case findings.FindingUnk:
+			unknown = true
+			merged.Status.Open = worseStatus(merged.Status.Open, findings.FindingUnk)
 		}
 	}
-	return resp
+	// A region that couldn't be read may be the one recording global resources
+	if merged.Notes["GlobalResources"] == "" && !unknown {
+		merged.Status.Open = findings.FindingOpen
+		failing = append(failing, "no region records global resources")
+	}
+	merged.Notes["User"] = strings.Join(failing, ", ")
+	return merged
 }
 
-func ensureConfigEnabled(cs *configservice.ConfigService) findings.Finding {
-	// TODO: Make multi-region enabled
-	resp := findings.Finding{Name: "Finding 2.5", Description: Finding2_5Txt, Status: findings.Status{Checked: true, Open: findings.FindingOpen}, Notes: make(map[string]string)}
-	params := &configservice.DescribeConfigurationRecordersInput{}
+/*
+ensureCMKRotationEnabled lists every enabled customer managed key in the region with whether it
+rotates automatically.  Keys that can't rotate automatically (asymmetric, HMAC, imported key
+material or custom key stores) are listed as not applicable.  AWS managed keys rotate on their own
+and are left out, as are disabled keys and keys pending deletion.
+*/
+func ensureCMKRotationEnabled(kmsKeys *Keys) findings.Finding {
+	resp := findings.Finding{Name: "Finding 2.8", Description: Finding2_8Txt, Status: findings.Status{Checked: true, Open: findings.FindingClosed}, Notes: make(map[string]string)}
+	kmsSvc := kmsKeys.svc
+	region := aws.StringValue(kmsSvc.Config.Region)
 
-	cr, err := cs.DescribeConfigurationRecorders(params)
+	keys, err := kmsKeys.customer()
 	if err != nil {
-		panic(err)
+		resp.Status.Open = findings.FindingUnk
+		resp.Notes["User"] = fmt.Sprintf("Couldn't list keys in %s: %s", region, errorText(err))
+		return resp
 	}
-	for i := range cr.ConfigurationRecorders {
-		if *cr.ConfigurationRecorders[i].RecordingGroup.AllSupported && *cr.ConfigurationRecorders[i].RecordingGroup.IncludeGlobalResourceTypes {
-			resp.Status.Open = findings.FindingClosed
+	var open []string
+	for _, k := range keys {
+		if k.Err != nil {
+			resp.AddResource(region, k.ID, findings.FindingUnk, "Couldn't describe the key: "+errorText(k.Err))
+			resp.Status.Open = worseStatus(resp.Status.Open, findings.FindingUnk)
+			continue
+		}
+		if aws.StringValue(k.Meta.KeyState) != kms.KeyStateEnabled {
+			continue
+		}
+		if reason := cannotRotate(k.Meta); reason != "" {
+			resp.AddResource(region, k.ID, findings.FindingNA, reason+k.aliased())
+			continue
+		}
+		status, err := kmsSvc.GetKeyRotationStatus(&kms.GetKeyRotationStatusInput{KeyId: aws.String(k.ID)})
+		switch {
+		case err != nil:
+			resp.AddResource(region, k.ID, findings.FindingUnk, "Couldn't read the rotation status: "+errorText(err)+k.aliased())
+			resp.Status.Open = worseStatus(resp.Status.Open, findings.FindingUnk)
+		case aws.BoolValue(status.KeyRotationEnabled):
+			resp.AddResource(region, k.ID, findings.FindingClosed, "Automatic key rotation is enabled"+k.aliased())
+		default:
+			resp.AddResource(region, k.ID, findings.FindingOpen, "Automatic key rotation is not enabled"+k.aliased())
+			resp.Status.Open = findings.FindingOpen
+			open = append(open, k.name()+" ("+region+")")
 		}
 	}
+	resp.Notes["User"] = strings.Join(open, ", ")
 	return resp
 }
 
-func ensureCMKRotationEnabled(kmsSvc *kms.KMS) findings.Finding {
-	resp := findings.Finding{Name: "Finding 2.8", Description: Finding2_8Txt, Status: findings.Status{Checked: true, Open: findings.FindingOpen}, Notes: make(map[string]string)}
-
-	listparams := &kms.ListKeysInput{}
-	keys, err := kmsSvc.ListKeys(listparams)
-	if err != nil {
-		panic(err)
+// cannotRotate returns why a key can't rotate automatically, or "" if it can
+func cannotRotate(meta *kms.KeyMetadata) string {
+	spec := aws.StringValue(meta.KeySpec)
+	if spec == "" {
+		spec = aws.StringValue(meta.CustomerMasterKeySpec)
 	}
-	for k := range keys.Keys {
-		params := &kms.GetKeyRotationStatusInput{
-			KeyId: aws.String(*keys.Keys[k].KeyId), // Required
-		}
-		status, _ := kmsSvc.GetKeyRotationStatus(params)
-
-		if status.KeyRotationEnabled != nil {
-			isenabled := *status.KeyRotationEnabled
-			if isenabled {
-				resp.Status.Open = findings.FindingClosed
-			}
-		}
+	switch {
+	case spec != "" && spec != kms.KeySpecSymmetricDefault:
+		return spec + " keys can't be rotated automatically"
+	case aws.StringValue(meta.Origin) == kms.OriginTypeExternal:
+		return "Keys with imported key material can't be rotated automatically"
+	case aws.StringValue(meta.Origin) != kms.OriginTypeAwsKms:
+		return "Keys in a custom key store can't be rotated automatically"
+	}
+	return ""
+}
 
+// worseStatus returns unknown over passing, but keeps a failing status
+func worseStatus(current, next string) string {
+	if current == findings.FindingOpen {
+		return current
 	}
-	return resp
+	return next
 }
@@ -2,8 +2,10 @@ package benchmark
 
 import (
 	"fmt"
+	"os"
 	"strings"
 
+	"github.com/adamcrosby/aws-cis-scanner/utility/filterpattern"
 	"github.com/adamcrosby/aws-cis-scanner/utility/findings"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/cloudtrail"
@@ -20,7 +22,7 @@ var FilterPatterns = [14]string{
 	"{($.eventName=DeleteGroupPolicy)||($.eventName=DeleteRolePolicy)||($.eventName=DeleteUserPolicy)||($.eventName=PutGroupPolicy)||($.eventName=PutRolePolicy)||($.eventName=PutUserPolicy)||($.eventName=CreatePolicy)||($.eventName=DeletePolicy)||($.eventName=CreatePolicyVersion)||($.eventName=DeletePolicyVersion)||($.eventName=AttachRolePolicy)||($.eventName=DetachRolePolicy)||($.eventName=AttachUserPolicy)||($.eventName=DetachUserPolicy)||($.eventName=AttachGroupPolicy)||($.eventName=DetachGroupPolicy)}", // 3.4
 	"{ ($.eventName = CreateTrail) || ($.eventName = UpdateTrail) ||($.eventName = DeleteTrail) || ($.eventName = StartLogging) || ($.eventName = StopLogging) }",                                                                                                                                                                                                                                                                                                                                                               // 3.5
 	"{ ($.eventName = ConsoleLogin) && ($.errorMessage = \"Failed authentication\") }",                                                                                                                                                                                                                                                                                                                                                                                                                                          //3.6
-	"{($.eventSource = kms.amazonaws.com) && (($.eventName=DisableKey)||($.eventName=ScheduleKeyDeletion))} }",                                                                                                                                                                                                                                                                                                                                                                                                                  // 3.7
+	"{($.eventSource = kms.amazonaws.com) && (($.eventName=DisableKey)||($.eventName=ScheduleKeyDeletion))}",                                                                                                                                                                                                                                                                                                                                                                                                                    // 3.7
 	"{ ($.eventSource = s3.amazonaws.com) && (($.eventName = PutBucketAcl) || ($.eventName = PutBucketPolicy) || ($.eventName = PutBucketCors) || ($.eventName = PutBucketLifecycle) || ($.eventName = PutBucketReplication) || ($.eventName = DeleteBucketPolicy) || ($.eventName = DeleteBucketCors) || ($.eventName = DeleteBucketLifecycle) || ($.eventName = DeleteBucketReplication)) }",                                                                                                                                  // 3.8
 	"{($.eventSource = config.amazonaws.com) && (($.eventName=StopConfigurationRecorder)||($.eventName=DeleteDeliveryChannel)||($.eventName=PutDeliveryChannel)||($.eventName=PutConfigurationRecorder))}",                                                                                                                                                                                                                                                                                                                      // 3.9
 	"{ ($.eventName = AuthorizeSecurityGroupIngress) || ($.eventName = AuthorizeSecurityGroupEgress) || ($.eventName = RevokeSecurityGroupIngress) || ($.eventName = RevokeSecurityGroupEgress) || ($.eventName = CreateSecurityGroup) || ($.eventName = DeleteSecurityGroup)}",                                                                                                                                                                                                                                                 //3.10
@@ -30,184 +32,316 @@ var FilterPatterns = [14]string{
 	"{ ($.eventName = CreateVpc) || ($.eventName = DeleteVpc) || ($.eventName = ModifyVpcAttribute) || ($.eventName = AcceptVpcPeeringConnection) || ($.eventName = CreateVpcPeeringConnection) || ($.eventName = DeleteVpcPeeringConnection) || ($.eventName = RejectVpcPeeringConnection) || ($.eventName = AttachClassicLinkVpc) || ($.eventName = DetachClassicLinkVpc) || ($.eventName = DisableVpcClassicLink) || ($.eventName = EnableVpcClassicLink) }"}                                                                 //3.14
 
 /*
-MonitoringChecks runs the checks from 3.1-3.16 of the CIS benchmark
+MonitoringChecks runs the checks from 3.1-3.14 of the CIS benchmark in a region.  Only trails
+whose log group is in the region are looked at, as the log group, alarms and topics have to be
+read in their own region.  The trails come from the scan's trails, so each region's are only
+listed once.  A check passes if it passes in any region.
 */
-func MonitoringChecks(snsSvc *sns.SNS, cw *cloudwatch.CloudWatch, cwlogs *cloudwatchlogs.CloudWatchLogs, ct *cloudtrail.CloudTrail, checks findings.Checks) findings.Checks {
-
-	params := &cloudtrail.DescribeTrailsInput{
-		IncludeShadowTrails: aws.Bool(true),
-		TrailNameList:       []*string{},
+func MonitoringChecks(snsSvc *sns.SNS, cw *cloudwatch.CloudWatch, cwlogs *cloudwatchlogs.CloudWatchLogs, ct *cloudtrail.CloudTrail, trails *Trails, mon *Monitoring, checks findings.Checks) findings.Checks {
+	if !anyEnabled("3.1", "3.2", "3.3", "3.4", "3.5", "3.6", "3.7", "3.8", "3.9", "3.10", "3.11", "3.12", "3.13", "3.14") {
+		return checks
 	}
-	trails, err := ct.DescribeTrails(params)
 
-	if err != nil {
-		panic(err)
-	}
+	m := newMonitor(aws.StringValue(ct.Config.Region), cwlogs, cw, snsSvc)
+	// A region whose trails can't be listed might hold the trail that completes the chain
+	listErr := m.failed("trails", "trails", trails.add(ct))
+	m.addLogGroups(trails.list)
 
-	checks["Finding 3.1"] = findings.Finding{
-		Name:        "Finding 3.1",
-		Description: Finding3_1Txt,
-		Status: findings.Status{
-			Open:    filterAndAlarmExist(FilterPatterns[0], trails.TrailList, cwlogs, cw, snsSvc),
-			Checked: true}}
-	checks["Finding 3.2"] = findings.Finding{
-		Name:        "Finding 3.2",
-		Description: Finding3_2Txt,
-		Status: findings.Status{
-			Open:    filterAndAlarmExist(FilterPatterns[1], trails.TrailList, cwlogs, cw, snsSvc),
-			Checked: true}}
-	checks["Finding 3.3"] = findings.Finding{
-		Name:        "Finding 3.3",
-		Description: Finding3_3Txt,
-		Status: findings.Status{
-			Open:    filterAndAlarmExist(FilterPatterns[2], trails.TrailList, cwlogs, cw, snsSvc),
-			Checked: true}}
-	checks["Finding 3.4"] = findings.Finding{
-		Name:        "Finding 3.4",
-		Description: Finding3_4Txt,
-		Status: findings.Status{
-			Open:    filterAndAlarmExist(FilterPatterns[3], trails.TrailList, cwlogs, cw, snsSvc),
-			Checked: true}}
-	checks["Finding 3.5"] = findings.Finding{
-		Name:        "Finding 3.5",
-		Description: Finding3_5Txt,
-		Status: findings.Status{
-			Open:    filterAndAlarmExist(FilterPatterns[4], trails.TrailList, cwlogs, cw, snsSvc),
-			Checked: true}}
-	checks["Finding 3.6"] = findings.Finding{
-		Name:        "Finding 3.6",
-		Description: Finding3_6Txt,
-		Status: findings.Status{
-			Open:    filterAndAlarmExist(FilterPatterns[5], trails.TrailList, cwlogs, cw, snsSvc),
-			Checked: true}}
-	checks["Finding 3.7"] = findings.Finding{
-		Name:        "Finding 3.7",
-		Description: Finding3_7Txt,
-		Status: findings.Status{
-			Open:    filterAndAlarmExist(FilterPatterns[6], trails.TrailList, cwlogs, cw, snsSvc),
-			Checked: true}}
-	checks["Finding 3.8"] = findings.Finding{
-		Name:        "Finding 3.8",
-		Description: Finding3_8Txt,
-		Status: findings.Status{
-			Open:    filterAndAlarmExist(FilterPatterns[7], trails.TrailList, cwlogs, cw, snsSvc),
-			Checked: true}}
-	checks["Finding 3.9"] = findings.Finding{
-		Name:        "Finding 3.9",
-		Description: Finding3_9Txt,
-		Status: findings.Status{
-			Open:    filterAndAlarmExist(FilterPatterns[8], trails.TrailList, cwlogs, cw, snsSvc),
-			Checked: true}}
-	checks["Finding 3.10"] = findings.Finding{
-		Name:        "Finding 3.10",
-		Description: Finding3_10Txt,
-		Status: findings.Status{
-			Open:    filterAndAlarmExist(FilterPatterns[9], trails.TrailList, cwlogs, cw, snsSvc),
-			Checked: true}}
-	checks["Finding 3.11"] = findings.Finding{
-		Name:        "Finding 3.11",
-		Description: Finding3_11Txt,
-		Status: findings.Status{
-			Open:    filterAndAlarmExist(FilterPatterns[10], trails.TrailList, cwlogs, cw, snsSvc),
-			Checked: true}}
-	checks["Finding 3.12"] = findings.Finding{
-		Name:        "Finding 3.12",
-		Description: Finding3_12Txt,
-		Status: findings.Status{
-			Open:    filterAndAlarmExist(FilterPatterns[11], trails.TrailList, cwlogs, cw, snsSvc),
-			Checked: true}}
-	checks["Finding 3.13"] = findings.Finding{
-		Name:        "Finding 3.13",
-		Description: Finding3_13Txt,
-		Status: findings.Status{
-			Open:    filterAndAlarmExist(FilterPatterns[12], trails.TrailList, cwlogs, cw, snsSvc),
-			Checked: true}}
-	checks["Finding 3.14"] = findings.Finding{
-		Name:        "Finding 3.14",
-		Description: Finding3_14Txt,
-		Status: findings.Status{
-			Open:    filterAndAlarmExist(FilterPatterns[13], trails.TrailList, cwlogs, cw, snsSvc),
-			Checked: true}}
+	descriptions := [14]string{Finding3_1Txt, Finding3_2Txt, Finding3_3Txt, Finding3_4Txt, Finding3_5Txt, Finding3_6Txt, Finding3_7Txt,
+		Finding3_8Txt, Finding3_9Txt, Finding3_10Txt, Finding3_11Txt, Finding3_12Txt, Finding3_13Txt, Finding3_14Txt}
+
+	for i := range FilterPatterns {
+		name := fmt.Sprintf("Finding 3.%d", i+1)
+		if !Enabled(fmt.Sprintf("3.%d", i+1)) {
+			continue
+		}
+		result := m.evaluate(FilterPatterns[i])
+		if listErr != nil && result.stage != stagePassed {
+			result = monitoringResult{stage: stageNoLogGroup, unknown: true, note: trails.listError(m.region)}
+		}
+		if prev, ok := mon.results[name]; ok && !bestMonitoringResult(prev, result) {
+			continue
+		}
+		mon.results[name] = result
+		f := findings.Finding{
+			Name:        name,
+			Description: descriptions[i],
+			Status: findings.Status{
+				Open:    findings.FindingOpen,
+				Checked: true},
+			Notes: map[string]string{"User": result.note}}
+		if result.stage == stagePassed {
+			f.Status.Open = findings.FindingClosed
+			f.AddResource(m.region, result.resource, findings.FindingClosed, result.note)
+		} else if result.unknown {
+			f.Status.Open = findings.FindingUnk
+		}
+		checks[name] = f
+	}
 
 	return checks
 }
 
-func filterAndAlarmExist(pattern string, trails []*cloudtrail.Trail, cwlogs *cloudwatchlogs.CloudWatchLogs, cw *cloudwatch.CloudWatch, snsSvc *sns.SNS) string {
-	resp := false
+// The links in the chain a section 3 check follows, in order.  A result records how far along
+// the chain the best candidate got, so the note can say which link is missing.
+const (
+	stageNoLogGroup = iota
+	stageNoFilter
+	stageNoAlarm
+	stageNoAction
+	stageNoSubscriber
+	stagePassed
+)
 
-	// Get list of all Cloud Trails
-	for i := range trails {
-		// Determine if Cloud trail is cloudwatch enabled (should be at least one, per section 2.4)
-		if trails[i].CloudWatchLogsLogGroupArn != nil {
-
-			// ARN of form: arn:aws:logs:us-east-1:1234567980:log-group:CloudTrail/DefaultLogGroup:*'
-			// We need the 7th bit "CloudTrail/DefaultLogGroup" in this example, so split the string on ":"
-			logGroupName := strings.Split(*trails[i].CloudWatchLogsLogGroupArn, ":")[6]
-			metricFilterParams := &cloudwatchlogs.DescribeMetricFiltersInput{
-				LogGroupName: &logGroupName,
-			}
-			// Get a list of metric filters on this particular Cloudtrail Logs entry, and match it against the target string
-			// Then check to make sure there is an SNS alarm WITH at least one subscriber in order to pass the check
-			filters, err := cwlogs.DescribeMetricFilters(metricFilterParams)
-			if err != nil {
-				fmt.Println(err.Error())
-			}
+// Monitoring holds the best section 3 result of each check so far in a scan, so the result from
+// the region that got furthest along the chain is kept
+type Monitoring struct {
+	results map[string]monitoringResult
+}
 
-			for filteridx := range filters.MetricFilters {
-				// Check for pattern match
-				//const pattern = "{ ($.errorCode = \"*UnauthorizedOperation\") || ($.errorCode = \"AccessDenied*\") }"
-				filter := filters.MetricFilters[filteridx]
-				resp = checkForPatternInFilter(pattern, filter, cw, snsSvc)
-			}
-		}
-	}
+/*
+NewMonitoring returns an empty set of section 3 results, for a single scan
+*/
+func NewMonitoring() *Monitoring {
+	return &Monitoring{results: make(map[string]monitoringResult)}
+}
 
-	if resp {
-		return findings.FindingClosed
+/*
+bestMonitoringResult reports whether the result from another region is better than the one kept so
+far: a pass beats everything, then a result that couldn't be read (it might have passed), then
+whichever got further along the chain
+*/
+func bestMonitoringResult(prev, next monitoringResult) bool {
+	switch {
+	case prev.stage == stagePassed || next.stage == stagePassed:
+		return next.stage == stagePassed && prev.stage != stagePassed
+	case prev.unknown != next.unknown:
+		return next.unknown
 	}
-	return findings.FindingOpen
+	return next.stage > prev.stage
+}
 
+// monitoringResult is how far along the chain a check got in a region.  If it didn't pass and
+// some of the chain couldn't be read, unknown is set and the note holds the errors instead.
+type monitoringResult struct {
+	stage    int
+	note     string
+	resource string
+	unknown  bool
 }
 
-func atLeastOneSubscriber(snsSvc *sns.SNS, alertARN *string) bool {
-	resp := false
+// monitor reads the log groups, filters, alarms and subscriptions of a region once, for all of
+// the section 3 checks
+type monitor struct {
+	region      string
+	cwlogs      *cloudwatchlogs.CloudWatchLogs
+	cw          *cloudwatch.CloudWatch
+	snsSvc      *sns.SNS
+	logGroups   []string
+	filters     map[string][]*cloudwatchlogs.MetricFilter
+	alarms      map[string][]*cloudwatch.MetricAlarm
+	subscribers map[string]int
+	errs        map[string]error
+}
+
+func newMonitor(region string, cwlogs *cloudwatchlogs.CloudWatchLogs, cw *cloudwatch.CloudWatch, snsSvc *sns.SNS) *monitor {
+	return &monitor{
+		region:      region,
+		cwlogs:      cwlogs,
+		cw:          cw,
+		snsSvc:      snsSvc,
+		filters:     make(map[string][]*cloudwatchlogs.MetricFilter),
+		alarms:      make(map[string][]*cloudwatch.MetricAlarm),
+		subscribers: make(map[string]int),
+		errs:        make(map[string]error)}
+}
 
-	snsParam := &sns.ListSubscriptionsByTopicInput{
-		TopicArn: alertARN,
+/*
+addLogGroups records the log groups in the region that any trail delivers to
+*/
+func (m *monitor) addLogGroups(trails []*cloudtrail.Trail) {
+	seen := make(map[string]bool)
+	for i := range trails {
+		// ARN of form: arn:aws:logs:us-east-1:1234567980:log-group:CloudTrail/DefaultLogGroup:*
+		// The region is the 4th part and the log group name the 7th
+		parts := strings.Split(aws.StringValue(trails[i].CloudWatchLogsLogGroupArn), ":")
+		if len(parts) < 7 || parts[3] != m.region || seen[parts[6]] {
+			continue
+		}
+		seen[parts[6]] = true
+		m.logGroups = append(m.logGroups, parts[6])
 	}
-	subscribers, err := snsSvc.ListSubscriptionsByTopic(snsParam)
+}
+
+/*
+evaluate follows the chain for a pattern: a metric filter on a trail's log group matching it, an
+alarm on one of the filter's metrics, an SNS action on the alarm and a confirmed subscriber on the
+topic.  Every filter, metric, alarm and action is tried, and the first complete chain passes.
+Filters are compared by meaning rather than text, so a filter with different spacing or term
+order, or one matching more events, still matches.
+*/
+func (m *monitor) evaluate(pattern string) monitoringResult {
+	required, err := filterpattern.Parse(pattern)
 	if err != nil {
-		fmt.Println(err.Error())
+		panic(fmt.Sprintf("invalid filter pattern %s: %s", pattern, err))
+	}
+	best := monitoringResult{stage: stageNoLogGroup, note: "No trail delivers to a CloudWatch Logs group in the scanned regions"}
+	var closestMissing []string
+	better := func(stage int, note string) {
+		if stage > best.stage {
+			best = monitoringResult{stage: stage, note: note}
+		}
 	}
-	if len(subscribers.Subscriptions) > 0 {
-		resp = true
+	// Anything that couldn't be read might have completed the chain
+	var unread []string
+	seen := make(map[string]bool)
+	failed := func(err error) bool {
+		if err != nil && !seen[err.Error()] {
+			seen[err.Error()] = true
+			unread = append(unread, err.Error())
+		}
+		return err != nil
 	}
-	return resp
-}
-
-func checkForPatternInFilter(pattern string, filter *cloudwatchlogs.MetricFilter, cw *cloudwatch.CloudWatch, snsSvc *sns.SNS) bool {
-	resp := false
-	if *filter.FilterPattern == pattern {
-
-		metricName := filter.MetricTransformations[0].MetricName
-		metricNamespace := filter.MetricTransformations[0].MetricNamespace
 
-		params := &cloudwatch.DescribeAlarmsForMetricInput{
-			MetricName: aws.String(*metricName),      // Required
-			Namespace:  aws.String(*metricNamespace), // Required
-		}
-		alarms, alarmerr := cw.DescribeAlarmsForMetric(params)
-		if alarmerr != nil {
-			fmt.Println(alarmerr.Error())
+	for _, group := range m.logGroups {
+		filters, err := m.metricFilters(group)
+		if failed(err) {
+			continue
 		}
+		better(stageNoFilter, fmt.Sprintf("No metric filter on %s matches the pattern", group))
+		for _, filter := range filters {
+			missing := filterMissing(required, filter)
+			if len(missing) > 0 {
+				// Keep the filter that comes closest, as long as it matches some of the pattern
+				if best.stage == stageNoFilter && len(missing) < len(required.DNF()) && (closestMissing == nil || len(missing) < len(closestMissing)) {
+					closestMissing = missing
+					best.note = fmt.Sprintf("Closest metric filter %s on %s is missing: %s", aws.StringValue(filter.FilterName), group, strings.Join(missing, "; "))
+				}
+				continue
+			}
+			filterName := aws.StringValue(filter.FilterName)
+			better(stageNoAlarm, fmt.Sprintf("Metric filter %s on %s matches, but no alarm watches its metric", filterName, group))
 
-		for alarmidx := range alarms.MetricAlarms {
-			// verify pointer is not null
-			if alarms.MetricAlarms[alarmidx].AlarmActions != nil {
-				resp = atLeastOneSubscriber(snsSvc, alarms.MetricAlarms[alarmidx].AlarmActions[0])
+			for _, t := range filter.MetricTransformations {
+				metric := aws.StringValue(t.MetricNamespace) + "/" + aws.StringValue(t.MetricName)
+				alarms, err := m.metricAlarms(aws.StringValue(t.MetricNamespace), aws.StringValue(t.MetricName))
+				if failed(err) {
+					continue
+				}
+				for _, alarm := range alarms {
+					alarmName := aws.StringValue(alarm.AlarmName)
+					better(stageNoAction, fmt.Sprintf("Alarm %s on metric %s (filter %s) has no enabled SNS action", alarmName, metric, filterName))
+					if alarm.ActionsEnabled != nil && !*alarm.ActionsEnabled {
+						continue
+					}
+					for _, action := range alarm.AlarmActions {
+						topic := aws.StringValue(action)
+						if parts := strings.Split(topic, ":"); len(parts) < 3 || parts[2] != "sns" {
+							continue
+						}
+						better(stageNoSubscriber, fmt.Sprintf("Topic %s of alarm %s on metric %s (filter %s) has no confirmed subscribers", topic, alarmName, metric, filterName))
+						n, err := m.confirmedSubscribers(topic)
+						if failed(err) {
+							continue
+						}
+						if n > 0 {
+							return monitoringResult{
+								stage:    stagePassed,
+								resource: group + "/" + filterName,
+								note:     fmt.Sprintf("Filter %s on %s, metric %s, alarm %s, topic %s (%d confirmed subscribers)", filterName, group, metric, alarmName, topic, n)}
+						}
+					}
+				}
 			}
 		}
 	}
+	if len(unread) > 0 {
+		best.unknown = true
+		best.note = "Couldn't read all of the monitoring chain: " + strings.Join(unread, "; ")
+	}
+	return best
+}
+
+/*
+metricFilters returns every metric filter on a log group, reading them the first time they're needed
+*/
+func (m *monitor) metricFilters(group string) ([]*cloudwatchlogs.MetricFilter, error) {
+	if filters, ok := m.filters[group]; ok {
+		return filters, m.errs["filters "+group]
+	}
+	var filters []*cloudwatchlogs.MetricFilter
+	err := m.cwlogs.DescribeMetricFiltersPages(&cloudwatchlogs.DescribeMetricFiltersInput{LogGroupName: aws.String(group)},
+		func(page *cloudwatchlogs.DescribeMetricFiltersOutput, lastPage bool) bool {
+			filters = append(filters, page.MetricFilters...)
+			return true
+		})
+	m.filters[group] = filters
+	return filters, m.failed("filters "+group, fmt.Sprintf("metric filters on %s", group), err)
+}
 
-	return resp
+/*
+metricAlarms returns the alarms on a metric, reading them the first time they're needed
+*/
+func (m *monitor) metricAlarms(namespace, metric string) ([]*cloudwatch.MetricAlarm, error) {
+	key := namespace + "/" + metric
+	if alarms, ok := m.alarms[key]; ok {
+		return alarms, m.errs["alarms "+key]
+	}
+	resp, err := m.cw.DescribeAlarmsForMetric(&cloudwatch.DescribeAlarmsForMetricInput{
+		MetricName: aws.String(metric),
+		Namespace:  aws.String(namespace)})
+	var alarms []*cloudwatch.MetricAlarm
+	if err == nil {
+		alarms = resp.MetricAlarms
+	}
+	m.alarms[key] = alarms
+	return alarms, m.failed("alarms "+key, fmt.Sprintf("alarms on metric %s", key), err)
+}
+
+/*
+confirmedSubscribers counts the subscriptions to a topic that have been confirmed.  Subscriptions
+waiting for confirmation don't deliver anything, so aren't counted.
+*/
+func (m *monitor) confirmedSubscribers(topic string) (int, error) {
+	if n, ok := m.subscribers[topic]; ok {
+		return n, m.errs["topic "+topic]
+	}
+	n := 0
+	err := m.snsSvc.ListSubscriptionsByTopicPages(&sns.ListSubscriptionsByTopicInput{TopicArn: aws.String(topic)},
+		func(page *sns.ListSubscriptionsByTopicOutput, lastPage bool) bool {
+			for _, sub := range page.Subscriptions {
+				if arn := aws.StringValue(sub.SubscriptionArn); strings.HasPrefix(arn, "arn:") {
+					n++
+				}
+			}
+			return true
+		})
+	m.subscribers[topic] = n
+	return n, m.failed("topic "+topic, fmt.Sprintf("subscriptions of %s", topic), err)
+}
+
+/*
+failed records an error reading part of the chain, so later checks get the same error, and
+reports it on stderr (stdout may be the JSON results).  The error returned names what was read.
+*/
+func (m *monitor) failed(key, what string, err error) error {
+	if err == nil {
+		return nil
+	}
+	err = fmt.Errorf("%s in %s: %s", what, m.region, errorText(err))
+	fmt.Fprintln(os.Stderr, "Error reading", err)
+	m.errs[key] = err
+	return err
+}
+
+/*
+filterMissing returns the parts of the required pattern a metric filter doesn't match.  Filters
+that aren't JSON patterns (eg: space delimited ones) match none of it.
+*/
+func filterMissing(required *filterpattern.Expr, filter *cloudwatchlogs.MetricFilter) []string {
+	candidate, err := filterpattern.Parse(aws.StringValue(filter.FilterPattern))
+	if err != nil {
+		return []string{required.String()}
+	}
+	return filterpattern.Missing(required, candidate)
 }
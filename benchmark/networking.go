@@ -2,169 +2,387 @@ package benchmark
 
 import (
 	"fmt"
+	"net"
+	"strings"
 
 	"github.com/adamcrosby/aws-cis-scanner/utility/findings"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
 )
 
-const port22Ingress = "Name=ip-permission.from-port,Values=22 Name=ip-permission.to-port,Values=22 Name=ip-permission.cidr,Values='0.0.0.0/0'"
-const port3389Ingress = "Name=ip-permission.from-port,Values=3389 Name=ip-permission.to-port,Values=3389 Name=ip-permission.cidr,Values='0.0.0.0/0'"
-
 /*
 DoNetworkChecks runs the network checks from Section 4 of the benchmark
 */
 func DoNetworkChecks(ec2Svc *ec2.EC2, checks findings.Checks) findings.Checks {
-	// Do some funky legwork here as this is called multiple times for each region.  ANY
-	// failure in ANY region fails the check entirely, so ensure it's not accidentally reset
-	if chk := checks["Finding 4.1"].Status.Checked; chk {
-		if checks["Finding 4.1"].Status.Open == findings.FindingClosed {
-			// Finding has been checked but is not already marked open: leave it as such.
-			open, sgs := checkSinglePortOpenToWorld(ec2Svc, "22")
-			checks["Finding 4.1"] = findings.Finding{
-				Name:        "Finding 4.1",
-				Description: Finding4_1Txt,
-				Status: findings.Status{
-					Open:    open,
-					Checked: true},
-				Notes: make(map[string]string)}
-			checks["Finding 4.1"].Notes["User"] = checks["Finding 4.1"].Notes["User"] + sgs
-		} //else the finding is unknown or open, just leave it.
-	} else {
-		// the check hasn't been run, so just run it
-		open, sgs := checkSinglePortOpenToWorld(ec2Svc, "22")
-		checks["Finding 4.1"] = findings.Finding{
-			Name:        "Finding 4.1",
-			Description: Finding4_1Txt,
-			Status: findings.Status{
-				Open:    open,
-				Checked: true},
-			Notes: make(map[string]string)}
-		checks["Finding 4.1"].Notes["User"] = checks["Finding 4.1"].Notes["User"] + sgs
-	}
-	// Redo the same check to ensure 4.2 isn't reset either.
-	if chk := checks["Finding 4.2"].Status.Checked; chk {
-		if checks["Finding 4.2"].Status.Open == findings.FindingClosed {
-			open, sgs := checkSinglePortOpenToWorld(ec2Svc, "3389")
-			checks["Finding 4.2"] = findings.Finding{
-				Name:        "Finding 4.2",
-				Description: Finding4_2Txt,
-				Status: findings.Status{
-					Open:    open,
-					Checked: true},
-				Notes: make(map[string]string)}
-			checks["Finding 4.2"].Notes["User"] = sgs
-		}
-	} else {
-		open, sgs := checkSinglePortOpenToWorld(ec2Svc, "3389")
-		checks["Finding 4.2"] = findings.Finding{
-			Name:        "Finding 4.2",
-			Description: Finding4_2Txt,
-			Status: findings.Status{
-				Open:    open,
-				Checked: true},
-			Notes: make(map[string]string)}
-		checks["Finding 4.2"].Notes["User"] = sgs
-	}
-
-	checks["Finding 4.3"] = findings.Finding{
-		Name:        "Finding 4.3",
-		Description: Finding4_3Txt,
-		Status: findings.Status{
-			Open:    checkFlowLogs(ec2Svc),
-			Checked: true}}
+	// This is called once for each region.  ANY failure in ANY region fails the check
+	// entirely, so merge this regions results into the ones already gathered.
+	region := aws.StringValue(ec2Svc.Config.Region)
 
-	checks["Finding 4.4"] = findings.Finding{
-		Name:        "Finding 4.4",
-		Description: Finding4_4Txt,
-		Status: findings.Status{
-			Open:    restrictDefaultSG(ec2Svc),
-			Checked: true}}
+	if Enabled("4.1") || Enabled("4.2") {
+		groups, err := securityGroups(ec2Svc)
+		world := worldPrefixLists(ec2Svc)
+		if Enabled("4.1") {
+			checks["Finding 4.1"] = findings.Merge(checks["Finding 4.1"], portOpenToWorld("Finding 4.1", Finding4_1Txt, region, groups, err, world, 22))
+		}
+		if Enabled("4.2") {
+			checks["Finding 4.2"] = findings.Merge(checks["Finding 4.2"], portOpenToWorld("Finding 4.2", Finding4_2Txt, region, groups, err, world, 3389))
+		}
+	}
+	if Enabled("4.3") {
+		checks["Finding 4.3"] = findings.Merge(checks["Finding 4.3"], flowLogsEnabled(ec2Svc, region))
+	}
+	if Enabled("4.4") {
+		checks["Finding 4.4"] = findings.Merge(checks["Finding 4.4"], defaultSGRestricted(ec2Svc, region))
+	}
 
 	return checks
 }
 
-func checkSinglePortOpenToWorld(ec2Svc *ec2.EC2, portNum string) (string, string) {
-
-	ipFrom := ec2.Filter{
-		Name:   aws.String("ip-permission.to-port"),
-		Values: []*string{aws.String(portNum)}}
-	ipTo := ec2.Filter{
-		Name:   aws.String("ip-permission.to-port"),
-		Values: []*string{aws.String(portNum)}}
-	ipCidr := ec2.Filter{
-		Name:   aws.String("ip-permission.cidr"),
-		Values: []*string{aws.String("0.0.0.0/0")}}
-	filters := []*ec2.Filter{&ipFrom, &ipTo, &ipCidr}
+/*
+flowLogsEnabled builds the finding for 4.3 in a single region, with a resource for each VPC.  A VPC passes
+when it has an active VPC level flow log capturing the configured traffic type (ALL also covers REJECT).
+*/
+func flowLogsEnabled(ec2Svc *ec2.EC2, region string) findings.Finding {
+	resp := findings.Finding{
+		Name:        "Finding 4.3",
+		Description: Finding4_3Txt,
+		Status: findings.Status{
+			Open:    findings.FindingClosed,
+			Checked: true},
+		Notes: make(map[string]string)}
+	required := Settings.Thresholds.FlowLogTrafficType
 
-	query := &ec2.DescribeSecurityGroupsInput{
-		Filters: filters,
-	}
-	sgs, err := ec2Svc.DescribeSecurityGroups(query)
+	logs := make(map[string][]*ec2.FlowLog)
+	err := ec2Svc.DescribeFlowLogsPages(&ec2.DescribeFlowLogsInput{}, func(page *ec2.DescribeFlowLogsOutput, last bool) bool {
+		for _, fl := range page.FlowLogs {
+			id := aws.StringValue(fl.ResourceId)
+			logs[id] = append(logs[id], fl)
+		}
+		return true
+	})
 	if err != nil {
-		panic(err)
+		resp.Status.Open = findings.FindingUnk
+		resp.Notes["User"] = fmt.Sprintf("Couldn't list the flow logs in %s: %s", region, accessText(err, "ec2:DescribeFlowLogs"))
+		return resp
 	}
-	sgList := ""
-	if len(sgs.SecurityGroups) > 0 {
 
-		for x := range sgs.SecurityGroups {
-			if sgList == "" {
-				sgList = fmt.Sprintf("%s", *sgs.SecurityGroups[x].GroupName)
-			} else {
-				sgList = fmt.Sprintf("%s, %s", sgList, *sgs.SecurityGroups[x].GroupName)
+	// Results are only added once every VPC has been read, so a failure part way through
+	// doesn't leave a partial list
+	var missing []string
+	var vpcs []findings.Resource
+	err = ec2Svc.DescribeVpcsPages(&ec2.DescribeVpcsInput{}, func(page *ec2.DescribeVpcsOutput, last bool) bool {
+		for _, vpc := range page.Vpcs {
+			id := aws.StringValue(vpc.VpcId)
+			var passing, other []string
+			for _, fl := range logs[id] {
+				desc := describeFlowLog(fl)
+				traffic := aws.StringValue(fl.TrafficType)
+				if aws.StringValue(fl.FlowLogStatus) == "ACTIVE" && (traffic == "ALL" || traffic == required) {
+					passing = append(passing, desc)
+				} else {
+					other = append(other, desc)
+				}
+			}
+			switch {
+			case len(passing) > 0:
+				vpcs = append(vpcs, findings.Resource{Region: region, ID: id, Open: findings.FindingClosed, Evidence: strings.Join(passing, "; ")})
+			case len(other) > 0:
+				vpcs = append(vpcs, findings.Resource{Region: region, ID: id, Open: findings.FindingOpen, Evidence: fmt.Sprintf("No active flow log capturing %s traffic: %s", required, strings.Join(other, "; "))})
+				missing = append(missing, id)
+			default:
+				vpcs = append(vpcs, findings.Resource{Region: region, ID: id, Open: findings.FindingOpen, Evidence: "No flow logs"})
+				missing = append(missing, id)
 			}
 		}
+		return true
+	})
+	if err != nil {
+		resp.Status.Open = findings.FindingUnk
+		resp.Notes["User"] = fmt.Sprintf("Couldn't list the VPCs in %s: %s", region, accessText(err, "ec2:DescribeVpcs"))
+		return resp
+	}
+	resp.Resources = vpcs
+	if len(missing) > 0 {
+		resp.Status.Open = findings.FindingOpen
+		resp.Notes["User"] = strings.Join(missing, ", ")
+	}
+	return resp
+}
 
-		return findings.FindingOpen, sgList // At least one SG has port 22 open to the world, so check passes
-	} // otherwise, resp stays true, check passes
-	return findings.FindingClosed, ""
+// describeFlowLog summarizes a flow log, eg: "fl-0123 ACTIVE, REJECT traffic to CloudWatch Logs group vpc-flow"
+func describeFlowLog(fl *ec2.FlowLog) string {
+	dest := aws.StringValue(fl.LogDestination)
+	switch aws.StringValue(fl.LogDestinationType) {
+	case "cloud-watch-logs", "":
+		dest = "CloudWatch Logs group " + aws.StringValue(fl.LogGroupName)
+	case "s3":
+		dest = "S3 " + dest
+	case "kinesis-data-firehose":
+		dest = "Kinesis Data Firehose " + dest
+	}
+	return fmt.Sprintf("%s %s, %s traffic to %s", aws.StringValue(fl.FlowLogId), aws.StringValue(fl.FlowLogStatus), aws.StringValue(fl.TrafficType), dest)
 }
 
-func checkFlowLogs(ec2Svc *ec2.EC2) string {
-	// The Audit check text doesn't specify what kind, how many or anything
-	// Just that 'a vpc' has 'flowlogging' with 'status'= 'ACTIVE', so
-	// loop through all the flowlogs and if any have 'active', return that.
-	resp := false
+/*
+defaultSGRestricted builds the finding for 4.4 in a single region, with a resource for each VPC's default
+security group.  Groups with any inbound or outbound rule fail.  The network interfaces still using each
+group are listed, as they will lose the access those rules give when the group is tightened.
+*/
+func defaultSGRestricted(ec2Svc *ec2.EC2, region string) findings.Finding {
+	resp := findings.Finding{
+		Name:        "Finding 4.4",
+		Description: Finding4_4Txt,
+		Status: findings.Status{
+			Open:    findings.FindingClosed,
+			Checked: true},
+		Notes: make(map[string]string)}
+	defaultGroup := []*ec2.Filter{{Name: aws.String("group-name"), Values: []*string{aws.String("default")}}}
 
-	param := &ec2.DescribeFlowLogsInput{}
-	flows, err := ec2Svc.DescribeFlowLogs(param)
+	var groups []*ec2.SecurityGroup
+	err := ec2Svc.DescribeSecurityGroupsPages(&ec2.DescribeSecurityGroupsInput{Filters: defaultGroup}, func(page *ec2.DescribeSecurityGroupsOutput, last bool) bool {
+		groups = append(groups, page.SecurityGroups...)
+		return true
+	})
 	if err != nil {
-		panic(err)
+		resp.Status.Open = findings.FindingUnk
+		resp.Notes["User"] = fmt.Sprintf("Couldn't list the default security groups in %s: %s", region, accessText(err, "ec2:DescribeSecurityGroups"))
+		return resp
 	}
-	for f := range flows.FlowLogs {
-		if *flows.FlowLogs[f].FlowLogStatus == "ACTIVE" {
-			resp = true
-			break
+	attached := make(map[string][]string)
+	err = ec2Svc.DescribeNetworkInterfacesPages(&ec2.DescribeNetworkInterfacesInput{Filters: defaultGroup}, func(page *ec2.DescribeNetworkInterfacesOutput, last bool) bool {
+		for _, eni := range page.NetworkInterfaces {
+			for _, g := range eni.Groups {
+				id := aws.StringValue(g.GroupId)
+				attached[id] = append(attached[id], aws.StringValue(eni.NetworkInterfaceId))
+			}
 		}
+		return true
+	})
+	if err != nil {
+		resp.Status.Open = findings.FindingUnk
+		resp.Notes["User"] = fmt.Sprintf("Couldn't list the network interfaces in %s: %s", region, accessText(err, "ec2:DescribeNetworkInterfaces"))
+		return resp
+	}
+
+	var open []string
+	for _, sg := range groups {
+		id := aws.StringValue(sg.GroupId)
+		var rules []string
+		for _, perm := range sg.IpPermissions {
+			rules = append(rules, describeRules("inbound", "from", perm)...)
+		}
+		for _, perm := range sg.IpPermissionsEgress {
+			rules = append(rules, describeRules("outbound", "to", perm)...)
+		}
+		evidence := aws.StringValue(sg.VpcId) + ": "
+		status := findings.FindingClosed
+		if len(rules) > 0 {
+			status = findings.FindingOpen
+			open = append(open, id)
+			evidence += strings.Join(rules, "; ")
+		} else {
+			evidence += "no rules"
+		}
+		if enis := attached[id]; len(enis) > 0 {
+			evidence += fmt.Sprintf(".  Attached to %d network interfaces: %s", len(enis), strings.Join(enis, ", "))
+		}
+		resp.AddResource(region, id, status, evidence)
 	}
-	if resp {
-		return findings.FindingClosed
+	if len(open) > 0 {
+		resp.Status.Open = findings.FindingOpen
+		resp.Notes["User"] = strings.Join(open, ", ")
 	}
-	return findings.FindingOpen
+	return resp
 }
 
-func restrictDefaultSG(ec2Svc *ec2.EC2) string {
-	resp := false
-	defaultGroupName := ec2.Filter{
-		Name:   aws.String("group-name"),
-		Values: []*string{aws.String("default")}}
+// describeRules describes each source (or destination) of a rule, eg: "inbound tcp port 22 from 10.0.0.0/8"
+func describeRules(direction, preposition string, perm *ec2.IpPermission) []string {
+	traffic := "all traffic"
+	if p := strings.ToLower(aws.StringValue(perm.IpProtocol)); p != "-1" {
+		from, to := aws.Int64Value(perm.FromPort), aws.Int64Value(perm.ToPort)
+		if from == to {
+			traffic = fmt.Sprintf("%s port %d", p, from)
+		} else {
+			traffic = fmt.Sprintf("%s ports %d-%d", p, from, to)
+		}
+	}
+	var peers []string
+	for _, r := range perm.IpRanges {
+		peers = append(peers, aws.StringValue(r.CidrIp))
+	}
+	for _, r := range perm.Ipv6Ranges {
+		peers = append(peers, aws.StringValue(r.CidrIpv6))
+	}
+	for _, pl := range perm.PrefixListIds {
+		peers = append(peers, aws.StringValue(pl.PrefixListId))
+	}
+	for _, g := range perm.UserIdGroupPairs {
+		peers = append(peers, aws.StringValue(g.GroupId))
+	}
+	var rules []string
+	for _, peer := range peers {
+		rules = append(rules, fmt.Sprintf("%s %s %s %s", direction, traffic, preposition, peer))
+	}
+	return rules
+}
 
-	filters := []*ec2.Filter{&defaultGroupName}
+/*
+portOpenToWorld builds the finding for 4.1/4.2 in a single region, with a resource for each security group
+that has an ingress rule allowing the port from anywhere
+*/
+func portOpenToWorld(name, description, region string, groups []*ec2.SecurityGroup, listErr error, world func(string) (bool, error), port int64) findings.Finding {
+	resp := findings.Finding{
+		Name:        name,
+		Description: description,
+		Status: findings.Status{
+			Open:    findings.FindingClosed,
+			Checked: true},
+		Notes: make(map[string]string)}
+	if listErr != nil {
+		resp.Status.Open = findings.FindingUnk
+		resp.Notes["User"] = fmt.Sprintf("Couldn't list the security groups in %s: %s", region, accessText(listErr, "ec2:DescribeSecurityGroups"))
+		return resp
+	}
+	var names []string
+	for _, sg := range groups {
+		var rules, unread []string
+		for _, perm := range sg.IpPermissions {
+			open, errs := openToWorld(perm, port, world)
+			rules = append(rules, open...)
+			for _, err := range errs {
+				unread = append(unread, err.Error())
+			}
+		}
+		if len(rules) == 0 && len(unread) > 0 {
+			// A rule through a prefix list that couldn't be read may allow anyone
+			resp.Status.Open = worseStatus(resp.Status.Open, findings.FindingUnk)
+			resp.Resources = append(resp.Resources, findings.Resource{
+				Region:   region,
+				ID:       aws.StringValue(sg.GroupId),
+				Name:     aws.StringValue(sg.GroupName),
+				Open:     findings.FindingUnk,
+				Evidence: fmt.Sprintf("%s in %s: %s", aws.StringValue(sg.GroupName), aws.StringValue(sg.VpcId), strings.Join(unread, "; "))})
+			continue
+		}
+		if len(rules) == 0 {
+			continue
+		}
+		resp.Status.Open = findings.FindingOpen
+		names = append(names, aws.StringValue(sg.GroupName))
+		resp.Resources = append(resp.Resources, findings.Resource{
+			Region:   region,
+			ID:       aws.StringValue(sg.GroupId),
+			Name:     aws.StringValue(sg.GroupName),
+			Open:     findings.FindingOpen,
+			Evidence: fmt.Sprintf("%s in %s allows %s", aws.StringValue(sg.GroupName), aws.StringValue(sg.VpcId), strings.Join(rules, "; "))})
+	}
+	resp.Notes["User"] = strings.Join(names, ", ")
+	return resp
+}
 
-	query := &ec2.DescribeSecurityGroupsInput{
-		Filters: filters,
+/*
+openToWorld returns a description of each source in an ingress rule that lets anyone reach the port.
+A rule covers the port when its protocol is all traffic (-1), or TCP or UDP with a port range that
+includes it.  A source is anyone when it is an IPv4 or IPv6 range with a zero length prefix (eg:
+0.0.0.0/0 or ::/0), or a prefix list containing one.  Prefix lists that can't be read are returned as
+errors, as they may contain one.
+*/
+func openToWorld(perm *ec2.IpPermission, port int64, world func(string) (bool, error)) ([]string, []error) {
+	var traffic string
+	switch strings.ToLower(aws.StringValue(perm.IpProtocol)) {
+	case "-1":
+		traffic = "all traffic"
+	case "tcp", "6", "udp", "17":
+		from, to := aws.Int64Value(perm.FromPort), aws.Int64Value(perm.ToPort)
+		if perm.FromPort != nil && perm.ToPort != nil && (from > port || to < port) {
+			return nil, nil
+		}
+		protocol := map[string]string{"6": "tcp", "17": "udp"}[aws.StringValue(perm.IpProtocol)]
+		if protocol == "" {
+			protocol = strings.ToLower(aws.StringValue(perm.IpProtocol))
+		}
+		if from == to {
+			traffic = fmt.Sprintf("%s port %d", protocol, from)
+		} else {
+			traffic = fmt.Sprintf("%s ports %d-%d", protocol, from, to)
+		}
+	default:
+		return nil, nil
 	}
-	sgs, err := ec2Svc.DescribeSecurityGroups(query)
-	if err != nil {
-		panic(err)
+
+	var sources []string
+	var errs []error
+	for _, r := range perm.IpRanges {
+		if anyAddress(aws.StringValue(r.CidrIp)) {
+			sources = append(sources, aws.StringValue(r.CidrIp))
+		}
 	}
-	for g := range sgs.SecurityGroups {
-		if sgs.SecurityGroups[g].IpPermissions == nil && sgs.SecurityGroups[g].IpPermissionsEgress == nil {
-			resp = true
+	for _, r := range perm.Ipv6Ranges {
+		if anyAddress(aws.StringValue(r.CidrIpv6)) {
+			sources = append(sources, aws.StringValue(r.CidrIpv6))
 		}
 	}
-	if resp {
-		return findings.FindingClosed
+	for _, pl := range perm.PrefixListIds {
+		open, err := world(aws.StringValue(pl.PrefixListId))
+		if err != nil {
+			errs = append(errs, err)
+		} else if open {
+			sources = append(sources, "prefix list "+aws.StringValue(pl.PrefixListId))
+		}
+	}
+	var rules []string
+	for _, src := range sources {
+		rules = append(rules, traffic+" from "+src)
+	}
+	return rules, errs
+}
+
+// anyAddress reports whether a CIDR block covers every address, eg: 0.0.0.0/0 or ::/0
+func anyAddress(cidr string) bool {
+	_, block, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return false
+	}
+	ones, _ := block.Mask.Size()
+	return ones == 0
+}
+
+/*
+securityGroups returns every security group in the region
+*/
+func securityGroups(ec2Svc *ec2.EC2) ([]*ec2.SecurityGroup, error) {
+	var groups []*ec2.SecurityGroup
+	err := ec2Svc.DescribeSecurityGroupsPages(&ec2.DescribeSecurityGroupsInput{}, func(page *ec2.DescribeSecurityGroupsOutput, last bool) bool {
+		groups = append(groups, page.SecurityGroups...)
+		return true
+	})
+	return groups, err
+}
+
+/*
+worldPrefixLists returns a function reporting whether a prefix list contains an entry covering every
+address.  Each list's entries are only fetched once, and a list that can't be read returns the error.
+*/
+func worldPrefixLists(ec2Svc *ec2.EC2) func(string) (bool, error) {
+	known := make(map[string]bool)
+	failed := make(map[string]error)
+	return func(id string) (bool, error) {
+		if open, ok := known[id]; ok {
+			return open, failed[id]
+		}
+		open := false
+		err := ec2Svc.GetManagedPrefixListEntriesPages(&ec2.GetManagedPrefixListEntriesInput{PrefixListId: aws.String(id)}, func(page *ec2.GetManagedPrefixListEntriesOutput, last bool) bool {
+			for _, e := range page.Entries {
+				if anyAddress(aws.StringValue(e.Cidr)) {
+					open = true
+				}
+			}
+			return !open
+		})
+		known[id] = open
+		if err != nil {
+			failed[id] = fmt.Errorf("couldn't read prefix list %s: %s", id, accessText(err, "ec2:GetManagedPrefixListEntries"))
+		}
+		return open, failed[id]
 	}
-	return findings.FindingOpen
 }
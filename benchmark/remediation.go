@@ -0,0 +1,269 @@
+package benchmark
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/adamcrosby/aws-cis-scanner/utility/findings"
+)
+
+// guide holds the steps to fix a check.  CLI commands may use "{resource}", "{user}" (the user
+// part of a resource like "alice/access_key_1") and "{region}".  A command using them is repeated
+// for each failing resource; when no resources are known, resource is shown in their place.
+// "{traffic-type}" is the configured flow log traffic type.  Password policy guides are built
+// from the thresholds when they're rendered.
+type guide struct {
+	resource       string
+	cli            []string
+	console        []string
+	passwordPolicy bool
+}
+
+/*
+passwordPolicyCommand returns the command setting the password policy to the configured
+thresholds, the same values the checks and remediate -apply use
+*/
+func passwordPolicyCommand() string {
+	t := Settings.Thresholds
+	return fmt.Sprintf("aws iam update-account-password-policy --require-uppercase-characters --require-lowercase-characters --require-symbols --require-numbers --minimum-password-length %d --password-reuse-prevention 24 --max-password-age %d", t.PasswordMinLength, t.PasswordMaxAgeDays)
+}
+
+// passwordPolicyConsole returns the console steps for the configured password policy thresholds
+func passwordPolicyConsole() []string {
+	t := Settings.Thresholds
+	return []string{
+		"Open IAM > Account settings > Password policy and choose Edit.",
+		fmt.Sprintf("Require at least one uppercase letter, lowercase letter, number and symbol, a minimum length of %d, password expiry of %d days and prevent reuse of the last 24 passwords.", t.PasswordMinLength, t.PasswordMaxAgeDays),
+		"Note: the CLI command replaces the whole policy, so include any settings you already have."}
+}
+
+var rootConsole = []string{
+	"Sign in to the console with the root account email address and password.",
+	"Open the account menu > Security credentials."}
+
+var guides = map[string]guide{
+	"1.1": {resource: "<root>",
+		cli: []string{"aws iam generate-credential-report", "aws iam get-credential-report --query Content --output text | base64 --decode | grep '^<root_account>'"},
+		console: []string{"Open IAM > Credential report and download it to find when the root account was last used.",
+			"Create IAM users or roles for the work root was used for, and stop signing in as root."}},
+	"1.2": {resource: "<user-name>",
+		cli: []string{"aws iam create-virtual-mfa-device --virtual-mfa-device-name {resource} --outfile {resource}-qr.png --bootstrap-method QRCodePNG",
+			"aws iam enable-mfa-device --user-name {resource} --serial-number arn:aws:iam::<account-id>:mfa/{resource} --authentication-code1 <code> --authentication-code2 <next-code>"},
+		console: []string{"Open IAM > Users and select the user.", "On the Security credentials tab choose Assign MFA device and follow the steps, or choose Manage console access and disable the password."}},
+	"1.3": {resource: "<user-name>/<credential>",
+		cli: []string{"aws iam list-access-keys --user-name {user}",
+			"aws iam update-access-key --user-name {user} --access-key-id <access-key-id> --status Inactive",
+			"aws iam delete-login-profile --user-name {user}"},
+		console: []string{"Open IAM > Users and select the user.", "On the Security credentials tab, make unused access keys inactive and disable console access if the password is unused."}},
+	"1.4": {resource: "<user-name>/<access-key>",
+		cli: []string{"aws iam create-access-key --user-name {user}",
+			"aws iam list-access-keys --user-name {user}",
+			"aws iam update-access-key --user-name {user} --access-key-id <old-access-key-id> --status Inactive",
+			"aws iam delete-access-key --user-name {user} --access-key-id <old-access-key-id>"},
+		console: []string{"Open IAM > Users and select the user.", "On the Security credentials tab create a new access key, update the application to use it, then make the old key inactive and delete it."}},
+	"1.5":  {passwordPolicy: true},
+	"1.6":  {passwordPolicy: true},
+	"1.7":  {passwordPolicy: true},
+	"1.8":  {passwordPolicy: true},
+	"1.9":  {passwordPolicy: true},
+	"1.10": {passwordPolicy: true},
+	"1.11": {passwordPolicy: true},
+	"1.12": {console: append(append([]string{}, rootConsole...), "Under Access keys, delete each key.  Root access keys can't be managed with the CLI by another identity.")},
+	"1.13": {console: append(append([]string{}, rootConsole...), "Under Multi-factor authentication (MFA) choose Assign MFA device, then Hardware TOTP token or Security key, and follow the steps.")},
+	"1.14": {console: []string{"Sign in as root and open the account menu > Account.", "Under Configure Security Challenge Questions choose Edit, and set three questions and answers.", "Store the answers somewhere secure, such as a password manager shared with the account owners."}},
+	"1.15": {resource: "<user-name>",
+		cli: []string{"aws iam list-user-policies --user-name {resource}",
+			"aws iam list-attached-user-policies --user-name {resource}",
+			"aws iam add-user-to-group --user-name {resource} --group-name <group-name>",
+			"aws iam delete-user-policy --user-name {resource} --policy-name <policy-name>",
+			"aws iam detach-user-policy --user-name {resource} --policy-arn <policy-arn>"},
+		console: []string{"Open IAM > User groups and create a group (or use an existing one) with the permissions the user needs.", "Add the user to the group, then remove the policies on the user's Permissions tab."}},
+	"2.1": {cli: []string{"aws cloudtrail create-trail --name <trail-name> --s3-bucket-name <bucket-name> --is-multi-region-trail",
+		"aws cloudtrail update-trail --name <trail-name> --is-multi-region-trail",
+		"aws cloudtrail put-event-selectors --trail-name <trail-name> --event-selectors '[{\"ReadWriteType\":\"All\",\"IncludeManagementEvents\":true}]'",
+		"aws cloudtrail start-logging --name <trail-name>"},
+		console: []string{"Open CloudTrail > Trails.", "Create a trail, or edit an existing one, and make sure it applies to all regions and logging is on.", "Under Management events choose both Read and Write."}},
+	"2.2": {resource: "<trail-name>",
+		cli:     []string{"aws cloudtrail update-trail --region {region} --name {resource} --enable-log-file-validation"},
+		console: []string{"Open CloudTrail > Trails and select the trail.", "Under General details choose Edit and enable Log file validation."}},
+	"2.3": {resource: "<bucket-name>",
+		cli: []string{"aws s3api get-bucket-acl --bucket {resource}",
+			"aws s3api put-bucket-acl --bucket {resource} --acl private",
+			"aws s3api get-bucket-policy --bucket {resource}",
+			"aws s3api put-public-access-block --bucket {resource} --public-access-block-configuration BlockPublicAcls=true,IgnorePublicAcls=true,BlockPublicPolicy=true,RestrictPublicBuckets=true"},
+		console: []string{"Open S3 and select the bucket.", "On the Permissions tab remove the Everyone and Authenticated users grants from the ACL, remove policy statements with a \"*\" principal, and turn on Block all public access."}},
+	"2.4": {resource: "<trail-name>",
+		cli: []string{"aws logs create-log-group --region {region} --log-group-name <log-group-name>",
+			"aws cloudtrail update-trail --region {region} --name {resource} --cloud-watch-logs-log-group-arn <log-group-arn> --cloud-watch-logs-role-arn <role-arn>"},
+		console: []string{"Open CloudTrail > Trails and select the trail.", "Under CloudWatch Logs choose Edit, enable it, and pick a log group and an IAM role CloudTrail can use."}},
+	"2.5": {cli: []string{"aws configservice put-configuration-recorder --region {region} --configuration-recorder name=default,roleARN=<role-arn> --recording-group allSupported=true,includeGlobalResourceTypes=<true in one region only>",
+		"aws configservice put-delivery-channel --region {region} --delivery-channel name=default,s3BucketName=<bucket-name>",
+		"aws configservice start-configuration-recorder --region {region} --configuration-recorder-name default"},
+		console: []string{"Open AWS Config in each region and choose Get started (or Settings).", "Record all resources, including global resources, and deliver to an S3 bucket."}},
+	"2.6": {resource: "<trail-name>",
+		cli: []string{"aws cloudtrail get-trail --region {region} --name {resource} --query Trail.S3BucketName --output text",
+			"aws s3api put-bucket-logging --bucket <bucket-name> --bucket-logging-status '{\"LoggingEnabled\":{\"TargetBucket\":\"<log-bucket-name>\",\"TargetPrefix\":\"<bucket-name>/\"}}'"},
+		console: []string{"Open S3 and select the CloudTrail bucket.", "On the Properties tab, edit Server access logging, enable it and pick a separate target bucket."}},
+	"2.7": {resource: "<trail-name>",
+		cli:     []string{"aws cloudtrail update-trail --region {region} --name {resource} --kms-key-id <kms-key-arn>"},
+		console: []string{"Open CloudTrail > Trails and select the trail.", "Under General details choose Edit, enable Log file SSE-KMS encryption and pick a key whose policy allows CloudTrail to use it."}},
+	"2.8": {resource: "<key-id>",
+		cli:     []string{"aws kms enable-key-rotation --region {region} --key-id {resource}"},
+		console: []string{"Open KMS > Customer managed keys and select the key.", "On the Key rotation tab tick Automatically rotate this KMS key every year and save."}},
+	"3.15": {cli: []string{"aws account put-alternate-contact --alternate-contact-type SECURITY --name <name> --title <title> --email-address <email-address> --phone-number <phone-number>"},
+		console: []string{"Open the account menu > Account.", "Under Alternate contacts choose Edit and fill in the Security contact.  Use a shared mailbox or distribution list."}},
+	"3.16": {resource: "<subscription-arn>",
+		cli: []string{"aws sns list-subscriptions-by-topic --topic-arn <topic-arn>",
+			"aws sns unsubscribe --region {region} --subscription-arn {resource}"},
+		console: []string{"Open SNS > Topics and select each topic.", "Review the Subscriptions tab and delete any subscription that isn't expected."}},
+	"4.1": {resource: "<security-group-id>",
+		cli: []string{"aws ec2 describe-security-group-rules --region {region} --filters Name=group-id,Values={resource} --query 'SecurityGroupRules[?!IsEgress].[SecurityGroupRuleId,IpProtocol,FromPort,ToPort,CidrIpv4,CidrIpv6,PrefixListId]' --output text",
+			"aws ec2 revoke-security-group-ingress --region {region} --group-id {resource} --security-group-rule-ids <rule-id>"},
+		console: []string{"Open EC2 > Security Groups and select the group.", "On the Inbound rules tab choose Edit inbound rules, and delete each rule covering port 22 (including all traffic rules and port ranges) from 0.0.0.0/0, ::/0 or a prefix list containing them, or change its source to a known range."}},
+	"4.2": {resource: "<security-group-id>",
+		cli: []string{"aws ec2 describe-security-group-rules --region {region} --filters Name=group-id,Values={resource} --query 'SecurityGroupRules[?!IsEgress].[SecurityGroupRuleId,IpProtocol,FromPort,ToPort,CidrIpv4,CidrIpv6,PrefixListId]' --output text",
+			"aws ec2 revoke-security-group-ingress --region {region} --group-id {resource} --security-group-rule-ids <rule-id>"},
+		console: []string{"Open EC2 > Security Groups and select the group.", "On the Inbound rules tab choose Edit inbound rules, and delete each rule covering port 3389 (including all traffic rules and port ranges) from 0.0.0.0/0, ::/0 or a prefix list containing them, or change its source to a known range."}},
+	"4.3": {resource: "<vpc-id>",
+		cli:     []string{"aws ec2 create-flow-logs --region {region} --resource-type VPC --resource-ids {resource} --traffic-type {traffic-type} --log-destination-type cloud-watch-logs --log-group-name <log-group-name> --deliver-logs-permission-arn <role-arn>"},
+		console: []string{"Open VPC > Your VPCs and select each VPC.", "On the Flow logs tab choose Create flow log, filter {traffic-type} and send it to CloudWatch Logs or S3."}},
+	"4.4": {resource: "<default-group-id>",
+		cli: []string{"aws ec2 describe-network-interfaces --region {region} --filters Name=group-id,Values={resource} --query 'NetworkInterfaces[].[NetworkInterfaceId,Description]'",
+			"aws ec2 revoke-security-group-ingress --region {region} --group-id {resource} --ip-permissions \"$(aws ec2 describe-security-groups --region {region} --group-ids {resource} --query 'SecurityGroups[0].IpPermissions')\"",
+			"aws ec2 revoke-security-group-egress --region {region} --group-id {resource} --ip-permissions \"$(aws ec2 describe-security-groups --region {region} --group-ids {resource} --query 'SecurityGroups[0].IpPermissionsEgress')\""},
+		console: []string{"Open EC2 > Security Groups and select each VPC's default group.", "Delete every inbound and outbound rule.  Move anything that relied on them to its own security group first."}},
+	"5.1": {resource: "<bucket-name>",
+		cli: []string{"aws s3api put-public-access-block --bucket {resource} --public-access-block-configuration BlockPublicAcls=true,IgnorePublicAcls=true,BlockPublicPolicy=true,RestrictPublicBuckets=true",
+			"aws s3api put-bucket-ownership-controls --bucket {resource} --ownership-controls Rules=[{ObjectOwnership=BucketOwnerEnforced}]",
+			"aws s3api get-bucket-policy --bucket {resource} --query Policy --output text",
+			"aws s3control list-access-points --region {region} --account-id <account-id> --bucket {resource}"},
+		console: []string{"Open S3 > Buckets and select the bucket.", "On the Permissions tab turn on Block all public access, set Object Ownership to Bucket owner enforced, and edit the bucket policy to remove statements for accounts that aren't trusted.", "On the Access Points tab review each access point's policy the same way."}},
+	"5.2": {resource: "<bucket-name>",
+		cli:     []string{"aws s3api put-bucket-encryption --region {region} --bucket {resource} --server-side-encryption-configuration '{\"Rules\":[{\"ApplyServerSideEncryptionByDefault\":{\"SSEAlgorithm\":\"aws:kms\",\"KMSMasterKeyID\":\"<kms-key-arn>\"},\"BucketKeyEnabled\":true}]}'"},
+		console: []string{"Open S3 > Buckets and select the bucket.", "On the Properties tab, edit Default encryption and choose SSE-KMS (or SSE-S3)."}},
+	"5.3": {resource: "<bucket-name>",
+		cli: []string{"aws s3api get-bucket-policy --region {region} --bucket {resource} --query Policy --output text",
+			"aws s3api put-bucket-policy --region {region} --bucket {resource} --policy '<existing statements plus {\"Sid\":\"DenyInsecureTransport\",\"Effect\":\"Deny\",\"Principal\":\"*\",\"Action\":\"s3:*\",\"Resource\":[\"arn:aws:s3:::{resource}\",\"arn:aws:s3:::{resource}/*\"],\"Condition\":{\"Bool\":{\"aws:SecureTransport\":\"false\"}}}>'"},
+		console: []string{"Open S3 > Buckets and select the bucket.", "On the Permissions tab, edit the bucket policy and add a statement denying s3:* to \"*\" when aws:SecureTransport is false."}},
+	"5.4": {resource: "<bucket-name>",
+		cli:     []string{"aws s3api put-bucket-versioning --region {region} --bucket {resource} --versioning-configuration Status=Enabled"},
+		console: []string{"Open S3 > Buckets and select the bucket.", "On the Properties tab, edit Bucket Versioning and choose Enable."}},
+	"5.5": {resource: "<bucket-name>",
+		cli:     []string{"aws s3api put-bucket-versioning --region {region} --bucket {resource} --versioning-configuration Status=Enabled,MFADelete=Enabled --mfa '<root-mfa-device-arn> <mfa-code>'"},
+		console: []string{"MFA Delete can't be enabled from the console.  Run the CLI command with the root user's credentials and MFA device."}},
+	"5.6": {resource: "<key-id>",
+		cli: []string{"aws kms get-key-policy --region {region} --key-id {resource} --policy-name default --query Policy --output text > key-policy.json",
+			"aws kms put-key-policy --region {region} --key-id {resource} --policy-name default --policy file://key-policy.json"},
+		console: []string{"Open KMS > Customer managed keys and select the key.", "On the Key policy tab switch to policy view, edit the statements with a \"*\" principal to name the accounts or roles that need the key, and save."}},
+	"5.7": {resource: "<key-id>",
+		cli: []string{"aws kms list-grants --region {region} --key-id {resource} --query 'Grants[].[GrantId,GranteePrincipal,Operations]'",
+			"aws kms revoke-grant --region {region} --key-id {resource} --grant-id <grant-id>",
+			"aws kms get-key-policy --region {region} --key-id {resource} --policy-name default --query Policy --output text > key-policy.json",
+			"aws kms put-key-policy --region {region} --key-id {resource} --policy-name default --policy file://key-policy.json"},
+		console: []string{"Open KMS > Customer managed keys and select the key.", "On the Key policy tab remove the other accounts that shouldn't use the key.  Grants can only be revoked with the CLI."}},
+	"5.8": {resource: "<key-id>",
+		cli: []string{"aws kms get-key-policy --region {region} --key-id {resource} --policy-name default --query Policy --output text > key-policy.json",
+			"aws kms put-key-policy --region {region} --key-id {resource} --policy-name default --policy file://key-policy.json"},
+		console: []string{"Open KMS > Customer managed keys and select the key.", "On the Key policy tab switch to policy view and add a statement allowing the account root principal (arn:aws:iam::<account-id>:root) kms:*.  This needs a principal the current policy still allows to call kms:PutKeyPolicy."}},
+}
+
+// MetricNamespace is the CloudWatch namespace of the metrics the suggested section 3 filters create
+const MetricNamespace = "CISBenchmark"
+
+/*
+MetricName returns the name of the metric (and filter and alarm) suggested for a section 3 check
+*/
+func MetricName(id string) string {
+	return "CIS-" + id
+}
+
+func init() {
+	// Section 3 checks all follow the same steps, with their own filter pattern.  The check
+	// matches patterns by meaning, and FilterPatterns are patterns it accepts, so they're used as is.
+	for i := range FilterPatterns {
+		id := fmt.Sprintf("3.%d", i+1)
+		metric := MetricName(id)
+		guides[id] = guide{
+			cli: []string{
+				fmt.Sprintf("aws logs put-metric-filter --log-group-name <cloudtrail-log-group> --filter-name %s --metric-transformations metricName=%s,metricNamespace=%s,metricValue=1 --filter-pattern '%s'", metric, metric, MetricNamespace, FilterPatterns[i]),
+				"aws sns create-topic --name <alarm-topic-name>",
+				"aws sns subscribe --topic-arn <alarm-topic-arn> --protocol email --notification-endpoint <security-team-email>",
+				fmt.Sprintf("aws cloudwatch put-metric-alarm --alarm-name %s --metric-name %s --namespace %s --statistic Sum --period 300 --threshold 1 --comparison-operator GreaterThanOrEqualToThreshold --evaluation-periods 1 --alarm-actions <alarm-topic-arn>", metric, metric, MetricNamespace)},
+			console: []string{
+				"Open CloudWatch > Log groups and select the log group the multi-region trail sends to.",
+				fmt.Sprintf("On the Metric filters tab create a filter with the pattern %s.", FilterPatterns[i]),
+				"Create an alarm on the filter's metric (Sum >= 1 over 5 minutes) that notifies an SNS topic, and subscribe the security team to the topic."}}
+	}
+}
+
+/*
+Remediation returns the guidance for fixing a finding, with the resources it failed against
+filled into the CLI commands
+*/
+func Remediation(f findings.Finding) *findings.Remediation {
+	c, ok := Lookup(f.ID())
+	if !ok {
+		return nil
+	}
+	g := guides[c.ID]
+	if g.passwordPolicy {
+		g.cli, g.console = []string{passwordPolicyCommand()}, passwordPolicyConsole()
+	}
+	r := &findings.Remediation{Explanation: c.Remediation}
+	for _, step := range g.console {
+		r.Console = append(r.Console, settingValues(step))
+	}
+
+	open := f.OpenResources()
+	for _, cmd := range g.cli {
+		cmd = settingValues(cmd)
+		if !usesResource(cmd) {
+			r.CLI = append(r.CLI, cmd)
+			continue
+		}
+		if len(open) == 0 {
+			r.CLI = append(r.CLI, fill(cmd, g.resource, "<region>"))
+			continue
+		}
+		// Several resources can give the same command, eg: two old access keys for one user
+		seen := make(map[string]bool)
+		for _, res := range open {
+			region := res.Region
+			if region == "" {
+				region = "<region>"
+			}
+			if filled := fill(cmd, res.ID, region); !seen[filled] {
+				seen[filled] = true
+				r.CLI = append(r.CLI, filled)
+			}
+		}
+	}
+	return r
+}
+
+/*
+AddRemediation attaches remediation guidance to every finding that isn't passing
+*/
+func AddRemediation(checks findings.Checks) {
+	for name, f := range checks {
+		if f.Status.Open == findings.FindingClosed || f.Status.Open == "" {
+			continue
+		}
+		f.Remediation = Remediation(f)
+		checks[name] = f
+	}
+}
+
+// settingValues fills the configured settings a guide uses into a command or console step
+func settingValues(s string) string {
+	return strings.Replace(s, "{traffic-type}", Settings.Thresholds.FlowLogTrafficType, -1)
+}
+
+func usesResource(cmd string) bool {
+	return strings.Contains(cmd, "{resource}") || strings.Contains(cmd, "{user}") || strings.Contains(cmd, "{region}")
+}
+
+func fill(cmd, resource, region string) string {
+	user := strings.SplitN(resource, "/", 2)[0]
+	return strings.NewReplacer("{resource}", resource, "{user}", user, "{region}", region).Replace(cmd)
+}
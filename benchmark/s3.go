@@ -0,0 +1,604 @@
+package benchmark
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/adamcrosby/aws-cis-scanner/utility/findings"
+	"github.com/adamcrosby/aws-cis-scanner/utility/policy"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudtrail"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3control"
+)
+
+// publicAccessBlock is the part of the S3 Block Public Access settings that changes who can reach
+// an existing bucket.  BlockPublicAcls and BlockPublicPolicy only stop new grants being made.
+type publicAccessBlock struct {
+	IgnorePublicAcls      bool
+	RestrictPublicBuckets bool
+	Sources               []string
+}
+
+/*
+buckets looks at S3 buckets from their own regions.  Bucket regions, clients and the account's
+Block Public Access settings are looked up once and reused.
+*/
+type buckets struct {
+	svc        *s3.S3
+	ctl        *s3control.S3Control
+	regions    map[string]string
+	clients    map[string]*s3.S3
+	controls   map[string]*s3control.S3Control
+	owner      *string
+	account    *publicAccessBlock
+	accountErr error
+}
+
+func newBuckets(s3Svc *s3.S3, ctl *s3control.S3Control) *buckets {
+	return &buckets{svc: s3Svc, ctl: ctl, regions: make(map[string]string), clients: make(map[string]*s3.S3), controls: make(map[string]*s3control.S3Control)}
+}
+
+/*
+client returns a client for the region a bucket is in, and the region
+*/
+func (b *buckets) client(bucket string) (*s3.S3, string, error) {
+	region, ok := b.regions[bucket]
+	if !ok {
+		loc, err := b.svc.GetBucketLocation(&s3.GetBucketLocationInput{Bucket: aws.String(bucket)})
+		if err != nil {
+			return nil, "", err
+		}
+		region = s3.NormalizeBucketLocation(aws.StringValue(loc.LocationConstraint))
+		b.regions[bucket] = region
+	}
+	if region == aws.StringValue(b.svc.Config.Region) {
+		return b.svc, region, nil
+	}
+	if c, ok := b.clients[region]; ok {
+		return c, region, nil
+	}
+	c := s3.New(session.Must(session.NewSession(b.svc.Config.Copy().WithRegion(region))))
+	b.clients[region] = c
+	return c, region, nil
+}
+
+/*
+list returns the names of every bucket in the account, and records the account's canonical ID
+*/
+func (b *buckets) list() ([]string, error) {
+	resp, err := b.svc.ListBuckets(&s3.ListBucketsInput{})
+	if err != nil {
+		return nil, err
+	}
+	b.owner = aws.String("")
+	if resp.Owner != nil {
+		b.owner = resp.Owner.ID
+	}
+	var names []string
+	for _, bucket := range resp.Buckets {
+		names = append(names, aws.StringValue(bucket.Name))
+	}
+	return names, nil
+}
+
+/*
+control returns an S3 Control client for a region, as access points are regional
+*/
+func (b *buckets) control(region string) *s3control.S3Control {
+	if b.ctl == nil || region == aws.StringValue(b.ctl.Config.Region) {
+		return b.ctl
+	}
+	if c, ok := b.controls[region]; ok {
+		return c
+	}
+	c := s3control.New(session.Must(session.NewSession(b.ctl.Config.Copy().WithRegion(region))))
+	b.controls[region] = c
+	return c
+}
+
+/*
+blockPublicAccess returns the Block Public Access settings in effect for a bucket: its own, and
+the account's if the scanned account owns it (ownerID is the canonical ID from the bucket ACL)
+*/
+func (b *buckets) blockPublicAccess(svc *s3.S3, bucket, ownerID string) (publicAccessBlock, error) {
+	bpa := publicAccessBlock{}
+	resp, err := svc.GetPublicAccessBlock(&s3.GetPublicAccessBlockInput{Bucket: aws.String(bucket)})
+	if err != nil && awsErrorCode(err) != "NoSuchPublicAccessBlockConfiguration" {
+		return bpa, err
+	}
+	if err == nil && resp.PublicAccessBlockConfiguration != nil {
+		bpa.add("bucket", resp.PublicAccessBlockConfiguration.IgnorePublicAcls, resp.PublicAccessBlockConfiguration.RestrictPublicBuckets)
+	}
+
+	if b.ctl == nil || Account == "" {
+		return bpa, nil
+	}
+	if b.owner == nil {
+		if _, err := b.list(); err != nil {
+			return bpa, err
+		}
+	}
+	if ownerID == "" || ownerID != aws.StringValue(b.owner) {
+		return bpa, nil
+	}
+	if b.account == nil && b.accountErr == nil {
+		b.account = &publicAccessBlock{}
+		resp, err := b.ctl.GetPublicAccessBlock(&s3control.GetPublicAccessBlockInput{AccountId: aws.String(Account)})
+		if err != nil && awsErrorCode(err) != "NoSuchPublicAccessBlockConfiguration" {
+			b.account, b.accountErr = nil, err
+		}
+		if err == nil && resp.PublicAccessBlockConfiguration != nil {
+			b.account.add("account", resp.PublicAccessBlockConfiguration.IgnorePublicAcls, resp.PublicAccessBlockConfiguration.RestrictPublicBuckets)
+		}
+	}
+	if b.accountErr != nil {
+		return bpa, b.accountErr
+	}
+	bpa.IgnorePublicAcls = bpa.IgnorePublicAcls || b.account.IgnorePublicAcls
+	bpa.RestrictPublicBuckets = bpa.RestrictPublicBuckets || b.account.RestrictPublicBuckets
+	bpa.Sources = append(bpa.Sources, b.account.Sources...)
+	return bpa, nil
+}
+
+func (p *publicAccessBlock) add(level string, ignoreAcls, restrictBuckets *bool) {
+	if aws.BoolValue(ignoreAcls) {
+		p.IgnorePublicAcls = true
+		p.Sources = append(p.Sources, level+" IgnorePublicAcls")
+	}
+	if aws.BoolValue(restrictBuckets) {
+		p.RestrictPublicBuckets = true
+		p.Sources = append(p.Sources, level+" RestrictPublicBuckets")
+	}
+}
+
+// bucketAccess is who can reach a bucket, and the grants and policy statements that let them
+type bucketAccess struct {
+	Region       string
+	Public       []string // open to anyone, or any authenticated AWS user
+	CrossAccount []string // open to accounts that aren't trusted
+	Trusted      []string // open to trusted accounts
+	Cancelled    []string // public, but cancelled by Block Public Access or a VPC only access point
+	Problems     []string // what couldn't be read
+}
+
+/*
+access works out who can reach a bucket through its ACL and bucket policy, once Block Public
+Access is taken into account.  With extended set it also looks at object ownership (ACLs are
+ignored when the bucket owner is enforced), ACL grants and policy statements for other accounts,
+and the bucket's access points and their policies.
+*/
+func (b *buckets) access(bucket string, extended bool) bucketAccess {
+	a := bucketAccess{}
+	svc, region, err := b.client(bucket)
+	if err != nil {
+		a.Problems = append(a.Problems, "Couldn't find the bucket's region: "+errorText(err))
+		return a
+	}
+	a.Region = region
+
+	aclsDisabled := false
+	if extended {
+		oc, err := svc.GetBucketOwnershipControls(&s3.GetBucketOwnershipControlsInput{Bucket: aws.String(bucket)})
+		switch {
+		case err != nil && awsErrorCode(err) == "OwnershipControlsNotFoundError":
+		case err != nil:
+			a.Problems = append(a.Problems, "Couldn't read object ownership: "+errorText(err))
+		case oc.OwnershipControls != nil:
+			for _, r := range oc.OwnershipControls.Rules {
+				aclsDisabled = aclsDisabled || aws.StringValue(r.ObjectOwnership) == s3.ObjectOwnershipBucketOwnerEnforced
+			}
+		}
+	}
+
+	var aclGrants, policyGrants []string
+	acl, err := svc.GetBucketAcl(&s3.GetBucketAclInput{Bucket: aws.String(bucket)})
+	ownerID := ""
+	if err != nil {
+		a.Problems = append(a.Problems, "Couldn't read the ACL: "+errorText(err))
+	} else {
+		if acl.Owner != nil {
+			ownerID = aws.StringValue(acl.Owner.ID)
+		}
+		for _, g := range acl.Grants {
+			if g.Grantee == nil || aclsDisabled {
+				continue
+			}
+			permission := aws.StringValue(g.Permission)
+			switch {
+			case aws.StringValue(g.Grantee.URI) == AllUsersURI:
+				aclGrants = append(aclGrants, fmt.Sprintf("ACL grants %s to %s", permission, policy.Anyone))
+			case aws.StringValue(g.Grantee.URI) == AuthenticedUsersURI:
+				aclGrants = append(aclGrants, fmt.Sprintf("ACL grants %s to %s", permission, policy.Authenticated))
+			case !extended:
+			case aws.StringValue(g.Grantee.Type) == s3.TypeCanonicalUser && aws.StringValue(g.Grantee.ID) != ownerID:
+				a.CrossAccount = append(a.CrossAccount, fmt.Sprintf("ACL grants %s to canonical user %s", permission, canonicalName(g.Grantee)))
+			case aws.StringValue(g.Grantee.Type) == s3.TypeAmazonCustomerByEmail:
+				a.CrossAccount = append(a.CrossAccount, fmt.Sprintf("ACL grants %s to %s", permission, aws.StringValue(g.Grantee.EmailAddress)))
+			}
+		}
+	}
+
+	resp, err := svc.GetBucketPolicy(&s3.GetBucketPolicyInput{Bucket: aws.String(bucket)})
+	switch {
+	case err != nil && awsErrorCode(err) == "NoSuchBucketPolicy":
+	case err != nil:
+		a.Problems = append(a.Problems, "Couldn't read the bucket policy: "+errorText(err))
+	default:
+		doc, err := policy.Parse(aws.StringValue(resp.Policy))
+		if err != nil {
+			a.Problems = append(a.Problems, "Couldn't parse the bucket policy: "+err.Error())
+			break
+		}
+		for _, e := range doc.Exposures() {
+			policyGrants = append(policyGrants, "bucket policy "+e.String())
+		}
+		if extended {
+			a.addCrossAccount("bucket policy ", doc)
+		}
+	}
+
+	bpa, err := b.blockPublicAccess(svc, bucket, ownerID)
+	if err != nil {
+		a.Problems = append(a.Problems, "Couldn't read Block Public Access: "+errorText(err))
+	}
+	a.addPublic(aclGrants, bpa.IgnorePublicAcls, bpa.cancelledBy("IgnorePublicAcls"))
+	a.addPublic(policyGrants, bpa.RestrictPublicBuckets, bpa.cancelledBy("RestrictPublicBuckets"))
+
+	if extended {
+		b.accessPoints(&a, bucket, bpa)
+	}
+	return a
+}
+
+/*
+accessPoints adds the exposure of a bucket's access points.  A policy open to anyone doesn't make
+an access point public if it only accepts requests from a VPC, or if Block Public Access
+(RestrictPublicBuckets) is on for the access point, the bucket or the account.
+*/
+func (b *buckets) accessPoints(a *bucketAccess, bucket string, bpa publicAccessBlock) {
+	ctl := b.control(a.Region)
+	if ctl == nil || Account == "" {
+		return
+	}
+	var names []string
+	err := ctl.ListAccessPointsPages(&s3control.ListAccessPointsInput{AccountId: aws.String(Account), Bucket: aws.String(bucket)}, func(page *s3control.ListAccessPointsOutput, last bool) bool {
+		for _, ap := range page.AccessPointList {
+			names = append(names, aws.StringValue(ap.Name))
+		}
+		return true
+	})
+	if err != nil {
+		a.Problems = append(a.Problems, "Couldn't list access points: "+errorText(err))
+		return
+	}
+	for _, name := range names {
+		ap, err := ctl.GetAccessPoint(&s3control.GetAccessPointInput{AccountId: aws.String(Account), Name: aws.String(name)})
+		if err != nil {
+			a.Problems = append(a.Problems, fmt.Sprintf("Couldn't read access point %s: %s", name, errorText(err)))
+			continue
+		}
+		resp, err := ctl.GetAccessPointPolicy(&s3control.GetAccessPointPolicyInput{AccountId: aws.String(Account), Name: aws.String(name)})
+		if err != nil {
+			if awsErrorCode(err) != "NoSuchAccessPointPolicy" {
+				a.Problems = append(a.Problems, fmt.Sprintf("Couldn't read the policy of access point %s: %s", name, errorText(err)))
+			}
+			continue
+		}
+		doc, err := policy.Parse(aws.StringValue(resp.Policy))
+		if err != nil {
+			a.Problems = append(a.Problems, fmt.Sprintf("Couldn't parse the policy of access point %s: %s", name, err))
+			continue
+		}
+		var grants []string
+		for _, e := range doc.Exposures() {
+			grants = append(grants, fmt.Sprintf("access point %s policy %s", name, e))
+		}
+		apBlock := publicAccessBlock{}
+		if c := ap.PublicAccessBlockConfiguration; c != nil {
+			apBlock.add("access point", c.IgnorePublicAcls, c.RestrictPublicBuckets)
+		}
+		switch {
+		case aws.StringValue(ap.NetworkOrigin) == s3control.NetworkOriginVpc:
+			vpc := "a VPC"
+			if ap.VpcConfiguration != nil {
+				vpc = aws.StringValue(ap.VpcConfiguration.VpcId)
+			}
+			a.addPublic(grants, true, "only reachable from "+vpc)
+		case apBlock.RestrictPublicBuckets:
+			a.addPublic(grants, true, "cancelled by access point RestrictPublicBuckets")
+		default:
+			a.addPublic(grants, bpa.RestrictPublicBuckets, bpa.cancelledBy("RestrictPublicBuckets"))
+		}
+		a.addCrossAccount(fmt.Sprintf("access point %s policy ", name), doc)
+	}
+}
+
+// addPublic records public grants, or notes why they don't count
+func (a *bucketAccess) addPublic(grants []string, cancelled bool, reason string) {
+	for _, g := range grants {
+		if cancelled {
+			a.Cancelled = append(a.Cancelled, g+" ("+reason+")")
+		} else {
+			a.Public = append(a.Public, g)
+		}
+	}
+}
+
+// addCrossAccount records the statements of a policy that other accounts can use
+func (a *bucketAccess) addCrossAccount(prefix string, doc *policy.Document) {
+	for _, e := range doc.CrossAccount(Account) {
+		if trustedAccount(strings.TrimPrefix(e.Who, "account ")) {
+			a.Trusted = append(a.Trusted, prefix+e.String())
+		} else {
+			a.CrossAccount = append(a.CrossAccount, prefix+e.String())
+		}
+	}
+}
+
+// cancelledBy describes where a Block Public Access setting that is on came from
+func (p publicAccessBlock) cancelledBy(setting string) string {
+	var levels []string
+	for _, s := range p.Sources {
+		if strings.HasSuffix(s, " "+setting) {
+			levels = append(levels, s)
+		}
+	}
+	return "cancelled by " + strings.Join(levels, " and ")
+}
+
+/*
+exposure is the verdict for 2.3: whether anyone, or any authenticated AWS user, can reach a
+bucket.  It returns the bucket's region, the status and the evidence.  If something couldn't be
+read and nothing public was found the status is unknown.
+*/
+func (b *buckets) exposure(bucket string) (string, string, string) {
+	a := b.access(bucket, false)
+	switch {
+	case len(a.Public) > 0:
+		return a.Region, findings.FindingOpen, strings.Join(a.Public, "; ")
+	case len(a.Problems) > 0:
+		return a.Region, findings.FindingUnk, strings.Join(a.Problems, "; ")
+	case len(a.Cancelled) > 0:
+		return a.Region, findings.FindingClosed, "Not public: " + strings.Join(a.Cancelled, "; ")
+	}
+	return a.Region, findings.FindingClosed, "Not public"
+}
+
+/*
+verdict is the result for 5.1: public, cross-account (to an account that isn't trusted) or private
+*/
+func (a bucketAccess) verdict() (string, string) {
+	switch {
+	case len(a.Public) > 0:
+		return findings.FindingOpen, "Public: " + strings.Join(append(a.Public, a.CrossAccount...), "; ")
+	case len(a.CrossAccount) > 0:
+		return findings.FindingOpen, "Cross-account: " + strings.Join(a.CrossAccount, "; ")
+	case len(a.Problems) > 0:
+		return findings.FindingUnk, strings.Join(a.Problems, "; ")
+	}
+	evidence := "Private"
+	if len(a.Trusted) > 0 {
+		evidence += ".  Shared with trusted accounts: " + strings.Join(a.Trusted, "; ")
+	}
+	if len(a.Cancelled) > 0 {
+		evidence += ".  Not public: " + strings.Join(a.Cancelled, "; ")
+	}
+	return findings.FindingClosed, evidence
+}
+
+// canonicalName names the grantee of an ACL grant to a canonical user
+func canonicalName(g *s3.Grantee) string {
+	if name := aws.StringValue(g.DisplayName); name != "" {
+		return name + " (" + aws.StringValue(g.ID) + ")"
+	}
+	return aws.StringValue(g.ID)
+}
+
+/*
+S3Checks runs the account wide S3 checks of Section 5.  Buckets are global, so it is run once per
+scan rather than once per region.  Each bucket is read from its own region.  5.5 only covers the
+trail buckets, so it is run with the trail checks.
+*/
+func S3Checks(s3Svc *s3.S3, s3ctl *s3control.S3Control, checks findings.Checks) findings.Checks {
+	b := newBuckets(s3Svc, s3ctl)
+	var names []string
+	var err error
+	if anyEnabled("5.1", "5.2", "5.3", "5.4") {
+		names, err = b.list()
+	}
+	if Enabled("5.1") {
+		checks["Finding 5.1"] = bucketFinding("Finding 5.1", Finding5_1Txt, names, err, func(bucket string) (string, string, string) {
+			a := b.access(bucket, true)
+			status, evidence := a.verdict()
+			return a.Region, status, evidence
+		})
+	}
+	if Enabled("5.2") {
+		checks["Finding 5.2"] = bucketFinding("Finding 5.2", Finding5_2Txt, names, err, b.defaultEncryption)
+	}
+	if Enabled("5.3") {
+		checks["Finding 5.3"] = bucketFinding("Finding 5.3", Finding5_3Txt, names, err, b.secureTransport)
+	}
+	if Enabled("5.4") {
+		checks["Finding 5.4"] = bucketFinding("Finding 5.4", Finding5_4Txt, names, err, b.versioning)
+	}
+	return checks
+}
+
+/*
+bucketFinding builds a finding with a resource for each bucket, from a check returning the bucket's
+region, status and evidence.  It is open if any bucket is, and unknown if the buckets couldn't be
+listed or one couldn't be checked.
+*/
+func bucketFinding(name, description string, names []string, err error, check func(bucket string) (string, string, string)) findings.Finding {
+	resp := findings.Finding{Name: name, Description: description, Status: findings.Status{Checked: true, Open: findings.FindingClosed}, Notes: make(map[string]string)}
+	if err != nil {
+		resp.Status.Open = findings.FindingUnk
+		resp.Notes["User"] = "Couldn't list buckets: " + errorText(err)
+		return resp
+	}
+	var failing []string
+	for _, bucket := range names {
+		region, status, evidence := check(bucket)
+		resp.AddResource(region, bucket, status, evidence)
+		switch {
+		case status == findings.FindingOpen:
+			resp.Status.Open = findings.FindingOpen
+			failing = append(failing, bucket)
+		case status == findings.FindingUnk && resp.Status.Open == findings.FindingClosed:
+			resp.Status.Open = findings.FindingUnk
+		}
+	}
+	resp.Notes["User"] = strings.Join(failing, ", ")
+	return resp
+}
+
+// trailBuckets returns the buckets trails deliver to, each once
+func trailBuckets(trails []*cloudtrail.Trail) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for i := range trails {
+		if bucket := aws.StringValue(trails[i].S3BucketName); bucket != "" && !seen[bucket] {
+			seen[bucket] = true
+			names = append(names, bucket)
+		}
+	}
+	return names
+}
+
+/*
+defaultEncryption is the check for 5.2: the bucket encrypts new objects with SSE-S3 or SSE-KMS
+*/
+func (b *buckets) defaultEncryption(bucket string) (string, string, string) {
+	svc, region, err := b.client(bucket)
+	if err != nil {
+		return "", findings.FindingUnk, "Couldn't find the bucket's region: " + errorText(err)
+	}
+	resp, err := svc.GetBucketEncryption(&s3.GetBucketEncryptionInput{Bucket: aws.String(bucket)})
+	switch {
+	case err != nil && awsErrorCode(err) == "ServerSideEncryptionConfigurationNotFoundError":
+		return region, findings.FindingOpen, "No default encryption"
+	case err != nil:
+		return region, findings.FindingUnk, "Couldn't read default encryption: " + errorText(err)
+	case resp.ServerSideEncryptionConfiguration == nil:
+		return region, findings.FindingOpen, "No default encryption"
+	}
+	for _, r := range resp.ServerSideEncryptionConfiguration.Rules {
+		if r.ApplyServerSideEncryptionByDefault == nil {
+			continue
+		}
+		switch d := r.ApplyServerSideEncryptionByDefault; aws.StringValue(d.SSEAlgorithm) {
+		case s3.ServerSideEncryptionAes256:
+			return region, findings.FindingClosed, "SSE-S3"
+		case s3.ServerSideEncryptionAwsKms, s3.ServerSideEncryptionAwsKmsDsse:
+			if key := aws.StringValue(d.KMSMasterKeyID); key != "" {
+				return region, findings.FindingClosed, "SSE-KMS with " + key
+			}
+			return region, findings.FindingClosed, "SSE-KMS with the AWS managed key"
+		}
+	}
+	return region, findings.FindingOpen, "No default encryption"
+}
+
+/*
+secureTransport is the check for 5.3: the bucket policy denies requests made without TLS
+*/
+func (b *buckets) secureTransport(bucket string) (string, string, string) {
+	svc, region, err := b.client(bucket)
+	if err != nil {
+		return "", findings.FindingUnk, "Couldn't find the bucket's region: " + errorText(err)
+	}
+	resp, err := svc.GetBucketPolicy(&s3.GetBucketPolicyInput{Bucket: aws.String(bucket)})
+	switch {
+	case err != nil && awsErrorCode(err) == "NoSuchBucketPolicy":
+		return region, findings.FindingOpen, "No bucket policy, so requests without TLS are allowed"
+	case err != nil:
+		return region, findings.FindingUnk, "Couldn't read the bucket policy: " + errorText(err)
+	}
+	doc, err := policy.Parse(aws.StringValue(resp.Policy))
+	if err != nil {
+		return region, findings.FindingUnk, "Couldn't parse the bucket policy: " + err.Error()
+	}
+	sid, ok := doc.DeniesInsecureTransport("s3:GetObject", "s3:PutObject")
+	switch {
+	case !ok:
+		return region, findings.FindingOpen, "The bucket policy doesn't deny requests where aws:SecureTransport is false"
+	case sid != "":
+		return region, findings.FindingClosed, fmt.Sprintf("Statement %q denies requests without TLS", sid)
+	}
+	return region, findings.FindingClosed, "The bucket policy denies requests without TLS"
+}
+
+/*
+versioning is the check for 5.4: versioning is enabled, not suspended or never turned on
+*/
+func (b *buckets) versioning(bucket string) (string, string, string) {
+	svc, region, err := b.client(bucket)
+	if err != nil {
+		return "", findings.FindingUnk, "Couldn't find the bucket's region: " + errorText(err)
+	}
+	resp, err := svc.GetBucketVersioning(&s3.GetBucketVersioningInput{Bucket: aws.String(bucket)})
+	if err != nil {
+		return region, findings.FindingUnk, "Couldn't read versioning: " + errorText(err)
+	}
+	switch aws.StringValue(resp.Status) {
+	case s3.BucketVersioningStatusEnabled:
+		return region, findings.FindingClosed, "Versioning enabled"
+	case s3.BucketVersioningStatusSuspended:
+		return region, findings.FindingOpen, "Versioning suspended"
+	}
+	return region, findings.FindingOpen, "Versioning not enabled"
+}
+
+/*
+mfaDelete is the check for 5.5: deleting object versions or changing versioning on a CloudTrail
+bucket needs the root user's MFA device
+*/
+func (b *buckets) mfaDelete(bucket string) (string, string, string) {
+	svc, region, err := b.client(bucket)
+	if err != nil {
+		return "", findings.FindingUnk, "Couldn't find the bucket's region: " + errorText(err)
+	}
+	resp, err := svc.GetBucketVersioning(&s3.GetBucketVersioningInput{Bucket: aws.String(bucket)})
+	if err != nil {
+		return region, findings.FindingUnk, "Couldn't read versioning: " + errorText(err)
+	}
+	if aws.StringValue(resp.MFADelete) == s3.MFADeleteStatusEnabled {
+		return region, findings.FindingClosed, "MFA Delete enabled"
+	}
+	status := aws.StringValue(resp.Status)
+	if status == "" {
+		status = "not enabled"
+	}
+	return region, findings.FindingOpen, "MFA Delete not enabled (versioning " + strings.ToLower(status) + ")"
+}
+
+// awsErrorCode returns the AWS error code of an error, eg: "NoSuchBucketPolicy"
+func awsErrorCode(err error) string {
+	if awsErr, ok := err.(awserr.Error); ok {
+		return awsErr.Code()
+	}
+	return ""
+}
+
+// accessText describes an error reading something for a check.  When access was denied it names
+// the permission the scanner is missing, eg: "the scanner needs ec2:DescribeFlowLogs".
+func accessText(err error, action string) string {
+	switch awsErrorCode(err) {
+	case "AccessDenied", "AccessDeniedException", "UnauthorizedOperation", "AuthorizationError":
+		return fmt.Sprintf("the scanner needs %s (%s)", action, errorText(err))
+	}
+	return errorText(err)
+}
+
+// errorText returns an error on one line, eg: "AccessDenied: Access Denied" rather than the SDK's
+// multi-line form with status codes and request IDs
+func errorText(err error) string {
+	if awsErr, ok := err.(awserr.Error); ok {
+		return awsErr.Code() + ": " + awsErr.Message()
+	}
+	return err.Error()
+}
@@ -0,0 +1,57 @@
+package benchmark
+
+import (
+	"strings"
+
+	"github.com/adamcrosby/aws-cis-scanner/utility/config"
+)
+
+// Settings holds the thresholds and check selection the checks run with.  It is replaced
+// with the loaded configuration before any checks are run.
+var Settings = config.Default()
+
+// Account is the ID of the account being scanned, for the APIs that need it (eg: the account's S3
+// Block Public Access settings).  It is set before any checks are run.
+var Account string
+
+/*
+Enabled reports whether a check (eg: "2.7") has been selected to run
+*/
+func Enabled(id string) bool {
+	return Settings.CheckEnabled(id)
+}
+
+func anyEnabled(ids ...string) bool {
+	for i := range ids {
+		if Enabled(ids[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func hours(days int) float64 {
+	return float64(days * 24)
+}
+
+// trustedAccount reports whether an account is listed in trusted_accounts
+func trustedAccount(id string) bool {
+	for _, t := range Settings.TrustedAccounts {
+		if t == id {
+			return true
+		}
+	}
+	return false
+}
+
+// trustedDomain reports whether a host is, or is under, one of the subscriber_domains
+func trustedDomain(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, d := range Settings.SubscriberDomains {
+		d = strings.ToLower(d)
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package benchmark
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/adamcrosby/aws-cis-scanner/utility/findings"
+	"github.com/adamcrosby/aws-cis-scanner/utility/policy"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sns"
+)
+
+/*
+SNSChecks runs check 3.16 in a region: an inventory of every topic's subscriptions for review.
+Deciding whether a subscriber is appropriate is left to the reviewer, but confirmed subscribers
+outside subscriber_domains, or in an account that isn't this one or a trusted one, fail the check.
+*/
+func SNSChecks(snsSvc *sns.SNS, checks findings.Checks) findings.Checks {
+	if !Enabled("3.16") {
+		return checks
+	}
+	region := aws.StringValue(snsSvc.Config.Region)
+	f := findings.Finding{
+		Name:        "Finding 3.16",
+		Description: Finding3_16Txt,
+		Status:      findings.Status{Checked: true, Open: findings.FindingClosed},
+		Notes:       make(map[string]string)}
+
+	var topics []string
+	err := snsSvc.ListTopicsPages(&sns.ListTopicsInput{}, func(page *sns.ListTopicsOutput, last bool) bool {
+		for _, t := range page.Topics {
+			topics = append(topics, aws.StringValue(t.TopicArn))
+		}
+		return true
+	})
+	if err != nil {
+		f.Status.Open = findings.FindingUnk
+		f.Notes["User"] = fmt.Sprintf("Couldn't list topics in %s: %s", region, errorText(err))
+		checks[f.Name] = findings.Merge(checks[f.Name], f)
+		return checks
+	}
+
+	var flagged []string
+	for _, topic := range topics {
+		name := topicName(topic)
+		var subs []*sns.Subscription
+		err := snsSvc.ListSubscriptionsByTopicPages(&sns.ListSubscriptionsByTopicInput{TopicArn: aws.String(topic)},
+			func(page *sns.ListSubscriptionsByTopicOutput, last bool) bool {
+				subs = append(subs, page.Subscriptions...)
+				return true
+			})
+		if err != nil {
+			f.Status.Open = worseStatus(f.Status.Open, findings.FindingUnk)
+			f.Resources = append(f.Resources, findings.Resource{Region: region, ID: topic, Name: name, Open: findings.FindingUnk,
+				Evidence: "Couldn't list the subscriptions: " + errorText(err)})
+			continue
+		}
+		if len(subs) == 0 {
+			f.Resources = append(f.Resources, findings.Resource{Region: region, ID: topic, Name: name, Open: findings.FindingClosed,
+				Evidence: "No subscriptions"})
+			continue
+		}
+		for _, sub := range subs {
+			protocol, endpoint := aws.StringValue(sub.Protocol), aws.StringValue(sub.Endpoint)
+			id := aws.StringValue(sub.SubscriptionArn)
+			state := "confirmed"
+			if !strings.HasPrefix(id, "arn:") {
+				// Subscriptions waiting for confirmation have no ARN yet, and deliver nothing until
+				// they are confirmed, so they are listed but don't fail the check
+				state = "pending confirmation"
+				id = topic + " " + protocol + ":" + endpoint
+			}
+			allowed, verdict := subscriberAllowed(protocol, endpoint)
+			status := findings.FindingClosed
+			if !allowed && state == "confirmed" {
+				status = findings.FindingOpen
+				f.Status.Open = findings.FindingOpen
+				flagged = append(flagged, fmt.Sprintf("%s %s on %s (%s)", protocol, endpoint, name, region))
+			}
+			f.Resources = append(f.Resources, findings.Resource{Region: region, ID: id, Name: name, Open: status,
+				Evidence: fmt.Sprintf("%s to %s, %s: %s", protocol, endpoint, state, verdict)})
+		}
+	}
+	f.Notes["User"] = strings.Join(flagged, ", ")
+	checks[f.Name] = findings.Merge(checks[f.Name], f)
+	return checks
+}
+
+/*
+subscriberAllowed decides whether a subscription's endpoint is covered by the allowlist: email
+domains and HTTP(S) hosts by subscriber_domains, and queues, functions, delivery streams and
+mobile endpoints by their account.  SMS numbers can only be reviewed by hand.
+*/
+func subscriberAllowed(protocol, endpoint string) (bool, string) {
+	switch protocol {
+	case "email", "email-json":
+		domain := endpoint[strings.LastIndex(endpoint, "@")+1:]
+		return domainAllowed("domain "+domain, domain)
+	case "http", "https":
+		u, err := url.Parse(endpoint)
+		if err != nil || u.Hostname() == "" {
+			return false, "can't read the host from the URL"
+		}
+		return domainAllowed("host "+u.Hostname(), u.Hostname())
+	case "sqs", "lambda", "firehose", "application":
+		account := policy.AccountOf(endpoint)
+		switch {
+		case account == "":
+			return false, "can't tell which account the endpoint is in"
+		case account == Account:
+			return true, "in this account"
+		case trustedAccount(account):
+			return true, fmt.Sprintf("in trusted account %s", account)
+		}
+		return false, fmt.Sprintf("in account %s, which isn't in trusted_accounts", account)
+	}
+	return true, "not covered by the allowlist, review by hand"
+}
+
+// domainAllowed checks a host against subscriber_domains, if any are set
+func domainAllowed(what, host string) (bool, string) {
+	switch {
+	case len(Settings.SubscriberDomains) == 0:
+		return true, what + ", no subscriber_domains set to check it against"
+	case trustedDomain(host):
+		return true, what + " is in subscriber_domains"
+	}
+	return false, what + " isn't in subscriber_domains"
+}
+
+// topicName returns the name at the end of a topic ARN
+func topicName(arn string) string {
+	return arn[strings.LastIndex(arn, ":")+1:]
+}
@@ -0,0 +1,338 @@
+package benchmark
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/adamcrosby/aws-cis-scanner/utility/findings"
+	"github.com/adamcrosby/aws-cis-scanner/utility/policy"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudtrail"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3control"
+)
+
+/*
+Trails is every trail seen so far in a scan, by ARN.  DescribeTrails lists a multi-region trail in
+every region, so each trail's results are kept and it is only evaluated once, with clients for its
+home region.  The trail checks are rebuilt from all the trails seen after each region.
+*/
+type Trails struct {
+	list    []*cloudtrail.Trail
+	seen    map[string]bool
+	results map[string]findings.Resource // by check and trail ARN (or bucket, for 2.3)
+	ct      *cloudtrail.CloudTrail
+	clients map[string]*cloudtrail.CloudTrail
+	buckets *buckets
+	listed  map[string]error // by region, once its trails have been listed
+	failed  []string         // regions whose trails couldn't be listed, in scan order
+}
+
+/*
+NewTrails returns an empty set of trails, for the start of a scan
+*/
+func NewTrails() *Trails {
+	return &Trails{seen: make(map[string]bool), results: make(map[string]findings.Resource), clients: make(map[string]*cloudtrail.CloudTrail), listed: make(map[string]error)}
+}
+
+/*
+add lists a region's trails, once, and records those that haven't been seen before.  A region whose
+trails can't be listed is recorded too, so the trail checks can report it, and the error is returned.
+*/
+func (t *Trails) add(ct *cloudtrail.CloudTrail) error {
+	region := aws.StringValue(ct.Config.Region)
+	if err, ok := t.listed[region]; ok {
+		return err
+	}
+	resp, err := ct.DescribeTrails(&cloudtrail.DescribeTrailsInput{IncludeShadowTrails: aws.Bool(true)})
+	t.listed[region] = err
+	if err != nil {
+		t.failed = append(t.failed, region)
+		return err
+	}
+	if t.ct == nil {
+		t.ct = ct
+	}
+	for _, trail := range resp.TrailList {
+		if key := trailKey(trail); !t.seen[key] {
+			t.seen[key] = true
+			t.list = append(t.list, trail)
+		}
+	}
+	return nil
+}
+
+// client returns a CloudTrail client for a trail's home region
+func (t *Trails) client(trail *cloudtrail.Trail) *cloudtrail.CloudTrail {
+	region := aws.StringValue(trail.HomeRegion)
+	if region == "" || region == aws.StringValue(t.ct.Config.Region) {
+		return t.ct
+	}
+	if c, ok := t.clients[region]; ok {
+		return c
+	}
+	c := cloudtrail.New(session.Must(session.NewSession(t.ct.Config.Copy().WithRegion(region))))
+	t.clients[region] = c
+	return c
+}
+
+// bucketsFor returns the bucket reader for trail buckets, created on first use
+func (t *Trails) bucketsFor(s3Svc *s3.S3, s3ctl *s3control.S3Control) *buckets {
+	if t.buckets == nil {
+		t.buckets = newBuckets(s3Svc, s3ctl)
+	}
+	return t.buckets
+}
+
+// result returns the resource a check found for a key, working it out the first time it's asked for
+func (t *Trails) result(check, key string, evaluate func() findings.Resource) findings.Resource {
+	r, ok := t.results[check+" "+key]
+	if !ok {
+		r = evaluate()
+		t.results[check+" "+key] = r
+	}
+	return r
+}
+
+/*
+finding builds a trail check with a resource for each trail.  Every trail must comply, so it is open
+if any trail fails or if there are no trails, and unknown if a trail couldn't be checked.
+*/
+func (t *Trails) finding(name, description string, check func(trail *cloudtrail.Trail) (string, string)) findings.Finding {
+	resp := findings.Finding{Name: name, Description: description, Status: findings.Status{Checked: true, Open: findings.FindingClosed}, Notes: make(map[string]string)}
+	if len(t.list) == 0 {
+		resp.Status.Open = findings.FindingOpen
+		resp.Notes["User"] = "No trails"
+		return resp
+	}
+	var failing []string
+	for _, trail := range t.list {
+		r := t.result(name, trailKey(trail), func() findings.Resource {
+			status, evidence := check(trail)
+			return findings.Resource{Region: aws.StringValue(trail.HomeRegion), ID: aws.StringValue(trail.Name), Open: status, Evidence: evidence}
+		})
+		resp.AddResource(r.Region, r.ID, r.Open, r.Evidence)
+		switch {
+		case r.Open == findings.FindingOpen:
+			resp.Status.Open = findings.FindingOpen
+			failing = append(failing, trailName(trail))
+		case r.Open == findings.FindingUnk && resp.Status.Open == findings.FindingClosed:
+			resp.Status.Open = findings.FindingUnk
+		}
+	}
+	resp.Notes["User"] = strings.Join(failing, ", ")
+	return resp
+}
+
+// listError describes why a region's trails couldn't be listed
+func (t *Trails) listError(region string) string {
+	return fmt.Sprintf("Couldn't list the trails in %s: %s", region, accessText(t.listed[region], "cloudtrail:DescribeTrails"))
+}
+
+/*
+unread adds the regions whose trails couldn't be listed to a trail check.  Their trails might fail
+it, so the check is unknown unless a trail already fails it.  2.1 only needs one covering trail, so
+it still passes if one was found.
+*/
+func (t *Trails) unread(f findings.Finding) findings.Finding {
+	if len(t.failed) == 0 {
+		return f
+	}
+	// With no trails listed, a note such as "No trails" would be misleading
+	var notes []string
+	if note := f.Notes["User"]; note != "" && len(t.list) > 0 {
+		notes = append(notes, note)
+	}
+	for _, region := range t.failed {
+		f.AddResource(region, region, findings.FindingUnk, t.listError(region))
+		notes = append(notes, t.listError(region))
+	}
+	switch {
+	case f.Name == "Finding 2.1" && f.Status.Open == findings.FindingClosed:
+		// A multi-region trail already covers the account, whatever the other regions hold
+	case f.Name == "Finding 2.1" || len(t.list) == 0 || f.Status.Open != findings.FindingOpen:
+		f.Status.Open = findings.FindingUnk
+	}
+	f.Notes["User"] = strings.Join(notes, "; ")
+	return f
+}
+
+// trailKey identifies a trail: its ARN, or its home region and name if the ARN is missing
+func trailKey(trail *cloudtrail.Trail) string {
+	if arn := aws.StringValue(trail.TrailARN); arn != "" {
+		return arn
+	}
+	return aws.StringValue(trail.HomeRegion) + "/" + aws.StringValue(trail.Name)
+}
+
+// trailName returns a trail's name, with the account it belongs to if that isn't the scanned one
+func trailName(trail *cloudtrail.Trail) string {
+	name := aws.StringValue(trail.Name)
+	if owner := policy.AccountOf(aws.StringValue(trail.TrailARN)); owner != "" && owner != Account {
+		name += " (account " + owner + ")"
+	}
+	return name
+}
+
+/*
+multiRegionEnabled passes when a multi-region trail is logging and records all management events,
+both read and write.  An organization trail from the management account counts for member accounts,
+which can't read its status or event selectors.
+*/
+func multiRegionEnabled(t *Trails) findings.Finding {
+	resp := findings.Finding{Name: "Finding 2.1", Description: Finding2_1Txt, Status: findings.Status{Checked: true, Open: findings.FindingOpen}, Notes: make(map[string]string)}
+
+	var failing []findings.Resource
+	var covered []string
+	unknown := false
+	for _, trail := range t.list {
+		r := t.result(resp.Name, trailKey(trail), func() findings.Resource {
+			status, evidence := t.coverage(trail)
+			return findings.Resource{Region: aws.StringValue(trail.HomeRegion), ID: aws.StringValue(trail.Name), Open: status, Evidence: evidence}
+		})
+		switch r.Open {
+		case findings.FindingClosed:
+			resp.AddResource(r.Region, r.ID, r.Open, r.Evidence)
+			covered = append(covered, trailName(trail))
+		case findings.FindingUnk:
+			unknown = true
+			failing = append(failing, r)
+		default:
+			failing = append(failing, r)
+		}
+	}
+
+	switch {
+	case len(covered) > 0:
+		resp.Status.Open = findings.FindingClosed
+		resp.Notes["User"] = "Covered by " + strings.Join(covered, ", ")
+	case len(t.list) == 0:
+		resp.Notes["User"] = "No trails"
+	default:
+		resp.Resources = failing
+		resp.Notes["User"] = "No multi-region trail is logging all management events"
+		if unknown {
+			resp.Status.Open = findings.FindingUnk
+		}
+	}
+	return resp
+}
+
+// coverage is a trail's result for 2.1
+func (t *Trails) coverage(trail *cloudtrail.Trail) (string, string) {
+	if !aws.BoolValue(trail.IsMultiRegionTrail) {
+		return findings.FindingOpen, trailName(trail) + " only logs its own region"
+	}
+	if owner := policy.AccountOf(aws.StringValue(trail.TrailARN)); aws.BoolValue(trail.IsOrganizationTrail) && owner != "" && owner != Account {
+		return findings.FindingClosed, fmt.Sprintf("%s is a multi-region organization trail from the management account %s", aws.StringValue(trail.Name), owner)
+	}
+	problem, err := trailCoverage(t.client(trail), trail)
+	switch {
+	case err != nil:
+		return findings.FindingUnk, trailName(trail) + ": " + errorText(err)
+	case problem != "":
+		return findings.FindingOpen, trailName(trail) + " " + problem
+	}
+	return findings.FindingClosed, trailName(trail) + " is multi-region, logging, and records all management events"
+}
+
+/*
+trailCoverage returns why a multi-region trail doesn't cover the account (eg: it is stopped), or ""
+if it does
+*/
+func trailCoverage(ct *cloudtrail.CloudTrail, trail *cloudtrail.Trail) (string, error) {
+	status, err := ct.GetTrailStatus(&cloudtrail.GetTrailStatusInput{Name: trail.TrailARN})
+	if err != nil {
+		return "", fmt.Errorf("couldn't read the trail status: %s", errorText(err))
+	}
+	if !aws.BoolValue(status.IsLogging) {
+		return "is not logging", nil
+	}
+	selectors, err := ct.GetEventSelectors(&cloudtrail.GetEventSelectorsInput{TrailName: trail.TrailARN})
+	if err != nil {
+		return "", fmt.Errorf("couldn't read the event selectors: %s", errorText(err))
+	}
+	for _, s := range selectors.EventSelectors {
+		if aws.BoolValue(s.IncludeManagementEvents) && aws.StringValue(s.ReadWriteType) == cloudtrail.ReadWriteTypeAll {
+			return "", nil
+		}
+	}
+	for _, s := range selectors.AdvancedEventSelectors {
+		if allManagementEvents(s) {
+			return "", nil
+		}
+	}
+	return "doesn't record all read and write management events", nil
+}
+
+// allManagementEvents reports whether an advanced event selector records every management event:
+// eventCategory is Management and no other field (eg: readOnly, or eventSource NotEquals
+// kms.amazonaws.com) leaves some of them out
+func allManagementEvents(s *cloudtrail.AdvancedEventSelector) bool {
+	management := false
+	for _, f := range s.FieldSelectors {
+		if aws.StringValue(f.Field) != "eventCategory" {
+			return false
+		}
+		for _, v := range f.Equals {
+			management = management || aws.StringValue(v) == "Management"
+		}
+	}
+	return management
+}
+
+// logValidation is a trail's result for 2.2
+func logValidation(trail *cloudtrail.Trail) (string, string) {
+	if aws.BoolValue(trail.LogFileValidationEnabled) {
+		return findings.FindingClosed, "Log file validation is enabled"
+	}
+	return findings.FindingOpen, "Log file validation is not enabled"
+}
+
+// cloudWatchDelivery is a trail's result for 2.4: it sends events to CloudWatch Logs, and has
+// delivered some in the last day
+func (t *Trails) cloudWatchDelivery(trail *cloudtrail.Trail) (string, string) {
+	group := aws.StringValue(trail.CloudWatchLogsLogGroupArn)
+	if group == "" {
+		return findings.FindingOpen, "Not sent to CloudWatch Logs"
+	}
+	status, err := t.client(trail).GetTrailStatus(&cloudtrail.GetTrailStatusInput{Name: trail.TrailARN})
+	switch {
+	case err != nil:
+		return findings.FindingUnk, "Couldn't read the trail status: " + errorText(err)
+	case status.LatestCloudWatchLogsDeliveryTime == nil:
+		return findings.FindingOpen, "Nothing has been delivered to " + group
+	case !isActiveInLastDay(status.LatestCloudWatchLogsDeliveryTime):
+		return findings.FindingOpen, fmt.Sprintf("Nothing delivered to %s since %s", group, status.LatestCloudWatchLogsDeliveryTime.UTC().Format("2006-01-02 15:04 MST"))
+	}
+	return findings.FindingClosed, "Delivering to " + group
+}
+
+// bucketLogging is a trail's result for 2.6: its bucket has server access logging
+func (t *Trails) bucketLogging(trail *cloudtrail.Trail) (string, string) {
+	bucket := aws.StringValue(trail.S3BucketName)
+	r := t.result("bucket logging", bucket, func() findings.Resource {
+		svc, region, err := t.buckets.client(bucket)
+		if err != nil {
+			return findings.Resource{Open: findings.FindingUnk, Evidence: "Couldn't find the region of bucket " + bucket + ": " + errorText(err)}
+		}
+		resp, err := svc.GetBucketLogging(&s3.GetBucketLoggingInput{Bucket: aws.String(bucket)})
+		switch {
+		case err != nil:
+			return findings.Resource{Region: region, Open: findings.FindingUnk, Evidence: "Couldn't read access logging of bucket " + bucket + ": " + errorText(err)}
+		case resp.LoggingEnabled == nil:
+			return findings.Resource{Region: region, Open: findings.FindingOpen, Evidence: "Bucket " + bucket + " doesn't have access logging enabled"}
+		}
+		return findings.Resource{Region: region, Open: findings.FindingClosed, Evidence: "Bucket " + bucket + " logs to " + aws.StringValue(resp.LoggingEnabled.TargetBucket)}
+	})
+	return r.Open, r.Evidence
+}
+
+// logEncryption is a trail's result for 2.7
+func logEncryption(trail *cloudtrail.Trail) (string, string) {
+	if key := aws.StringValue(trail.KmsKeyId); key != "" {
+		return findings.FindingClosed, "Encrypted with " + key
+	}
+	return findings.FindingOpen, "Trail is not encrypted with a KMS key"
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/adamcrosby/aws-cis-scanner/benchmark"
+	"github.com/adamcrosby/aws-cis-scanner/utility/config"
+	"github.com/adamcrosby/aws-cis-scanner/utility/findings"
+	"github.com/adamcrosby/aws-cis-scanner/utility/history"
+	"github.com/adamcrosby/aws-cis-scanner/utility/report"
+)
+
+/*
+listChecks implements the 'list-checks' command: the catalog of checks with their ID, title,
+whether they're scored, and the permissions needed to run them
+*/
+func listChecks(args []string) {
+	var sectionsPtr string
+	fs := flag.NewFlagSet("list-checks", flag.ExitOnError)
+	fs.StringVar(&sectionsPtr, "sections", "", "Comma separated list of sections to list, eg: '1,4'.  Default is every section.")
+	fs.Parse(args)
+
+	sections := splitList(sectionsPtr)
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tScored\tAutomated\tPermissions\tTitle")
+	for _, c := range benchmark.Catalog {
+		if len(sections) > 0 && !contains(sections, c.Section()) {
+			continue
+		}
+		permissions := strings.Join(c.Permissions, ", ")
+		if c.Manual() {
+			permissions = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", c.ID, findings.YesNo(c.Scored), findings.YesNo(!c.Manual()), permissions, c.Title)
+	}
+	w.Flush()
+}
+
+/*
+explainCheck implements the 'explain' command: why a check matters, how it is audited and how to fix it
+*/
+func explainCheck(args []string) {
+	if len(args) != 1 {
+		fmt.Println("Usage: aws-cis-scanner explain <check id>, eg: explain 3.7")
+		os.Exit(1)
+	}
+	c, ok := benchmark.Lookup(strings.TrimPrefix(args[0], "Finding "))
+	if !ok {
+		fmt.Printf("Unknown check %q.  Run 'aws-cis-scanner list-checks' to see every check.\n", args[0])
+		os.Exit(1)
+	}
+
+	fmt.Printf("%s %s\n\n", c.ID, c.Title)
+	fmt.Printf("Scored: %s\n", findings.YesNo(c.Scored))
+	if c.Manual() {
+		fmt.Println("Automated: no, this check has to be verified by hand")
+	} else {
+		fmt.Printf("Permissions: %s\n", strings.Join(c.Permissions, ", "))
+	}
+	fmt.Printf("\nRationale:\n  %s\n", c.Rationale)
+	fmt.Printf("\nAudit:\n  %s\n", c.Audit)
+	fmt.Printf("\nRemediation:\n  %s\n", c.Remediation)
+	r := benchmark.Remediation(findings.Finding{Name: "Finding " + c.ID})
+	if len(r.CLI) > 0 {
+		fmt.Println("\nAWS CLI:")
+		for _, cmd := range r.CLI {
+			fmt.Printf("  %s\n", cmd)
+		}
+	}
+	if len(r.Console) > 0 {
+		fmt.Println("\nConsole:")
+		for i, step := range r.Console {
+			fmt.Printf("  %d. %s\n", i+1, step)
+		}
+	}
+}
+
+/*
+renderReport implements the 'report' command: re-render a saved result file in any output format
+*/
+func renderReport(args []string) {
+	var formatPtr, outputPtr, historyPtr string
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	fs.StringVar(&formatPtr, "format", config.FormatHTML, "Output format, 'html' or 'json'.")
+	fs.StringVar(&outputPtr, "o", "", "File to write the output to.  Default is standard output.")
+	fs.StringVar(&historyPtr, "history", history.DefaultPath(), "Path to the scan history store, for the trend section of the HTML report.")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fmt.Println("Usage: aws-cis-scanner report [-format html|json] [-o file] results.json")
+		os.Exit(1)
+	}
+
+	scan, err := readScan(fs.Arg(0))
+	if err != nil {
+		fmt.Println("Error reading results:", err)
+		os.Exit(1)
+	}
+	o := config.Output{Format: formatPtr, Path: outputPtr}
+	if err := (config.Config{Thresholds: config.Default().Thresholds, Outputs: []config.Output{o}}).Validate(); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	// Results saved by older versions have no remediation guidance
+	benchmark.AddRemediation(scan.Checks)
+
+	// The trend is shown as it was when the scan was taken
+	data := report.Data{Checks: scan.Checks}
+	if store, err := history.Open(historyPtr); err == nil {
+		data.Trend = store.Trend(scan.Account, scan.Timestamp)
+	}
+	if err := writeOutput(o, scan, data); err != nil {
+		fmt.Println("Error writing output:", err)
+		os.Exit(1)
+	}
+}
+
+/*
+diffResults implements the 'diff' command: the checks and resources that changed between two result files
+*/
+func diffResults(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fmt.Println("Usage: aws-cis-scanner diff before.json after.json")
+		os.Exit(1)
+	}
+	before, err := readScan(fs.Arg(0))
+	if err != nil {
+		fmt.Println("Error reading results:", err)
+		os.Exit(1)
+	}
+	after, err := readScan(fs.Arg(1))
+	if err != nil {
+		fmt.Println("Error reading results:", err)
+		os.Exit(1)
+	}
+	if before.Account != after.Account {
+		fmt.Printf("Warning: comparing scans of different accounts (%s and %s)\n\n", before.Account, after.Account)
+	}
+
+	changes := findings.Diff(before, after)
+	if len(changes) == 0 {
+		fmt.Println("No changes.")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Finding\t%s\t%s\tTitle\n", before.Timestamp.Format(time.RFC3339), after.Timestamp.Format(time.RFC3339))
+	for _, c := range changes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", c.Name, statusText(c.Before), statusText(c.After), c.Description)
+		for _, r := range c.Opened {
+			fmt.Fprintf(w, "\t+ %s\t%s\t%s\n", r.ID, r.Region, r.Evidence)
+		}
+		for _, r := range c.Resolved {
+			fmt.Fprintf(w, "\t- %s\t%s\t%s\n", r.ID, r.Region, r.Evidence)
+		}
+	}
+	w.Flush()
+}
+
+/*
+printHistory implements the 'history' command: pass rate per section for every recorded
+scan, and how long each currently open finding has been open
+*/
+func printHistory(args []string) {
+	var historyPtr, accountPtr string
+	var lifecyclePtr bool
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	fs.StringVar(&historyPtr, "history", history.DefaultPath(), "Path to the scan history store.")
+	fs.StringVar(&accountPtr, "account", "", "Only show history for this account ID.  Default is every account in the store.")
+	fs.BoolVar(&lifecyclePtr, "lifecycle", false, "List every result fingerprint with its first seen, last seen and resolved times.")
+	fs.Parse(args)
+
+	store, err := history.Open(historyPtr)
+	if err != nil {
+		fmt.Println("Error opening scan history:", err)
+		os.Exit(1)
+	}
+	accountList := store.Accounts()
+	if accountPtr != "" {
+		accountList = []string{accountPtr}
+	}
+
+	now := time.Now().UTC()
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, account := range accountList {
+		trend := store.Trend(account, now)
+		fmt.Fprintf(w, "Account %s\n\n", account)
+		fmt.Fprintln(w, "Scan\tSection 1\tSection 2\tSection 3\tSection 4\tSection 5")
+		for _, t := range trend.Sections {
+			fmt.Fprint(w, t.Timestamp.Format(time.RFC3339))
+			for _, section := range []string{"1", "2", "3", "4", "5"} {
+				// Sections that weren't run in a scan have no pass rate
+				if t.Has(section) {
+					fmt.Fprintf(w, "\t%.1f%%", t.PassRate[section])
+				} else {
+					fmt.Fprint(w, "\t-")
+				}
+			}
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Open finding\tOpen since\tDays open\tTitle")
+		for _, o := range trend.Open {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", o.Name, o.OpenSince.Format(time.RFC3339), o.Days, o.Description)
+		}
+		fmt.Fprintln(w)
+		if lifecyclePtr {
+			fmt.Fprintln(w, "Fingerprint\tCheck\tRegion\tResource\tFirst seen\tLast seen\tResolved")
+			for _, l := range store.LifecyclesForAccount(account) {
+				resolved := "-"
+				if l.ResolvedAt != nil {
+					resolved = l.ResolvedAt.Format(time.RFC3339)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", l.Fingerprint, l.Check, l.Region, l.Resource,
+					l.FirstSeen.Format(time.RFC3339), l.LastSeen.Format(time.RFC3339), resolved)
+			}
+			fmt.Fprintln(w)
+		}
+	}
+	w.Flush()
+}
+
+func statusText(s string) string {
+	if s == "" {
+		return "Not Checked"
+	}
+	return s
+}
+
+func contains(list []string, s string) bool {
+	for i := range list {
+		if list[i] == s {
+			return true
+		}
+	}
+	return false
+}
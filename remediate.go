@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/adamcrosby/aws-cis-scanner/benchmark"
+	"github.com/adamcrosby/aws-cis-scanner/remediation"
+	"github.com/adamcrosby/aws-cis-scanner/utility/config"
+	"github.com/adamcrosby/aws-cis-scanner/utility/regions"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+const remediateUsage = `Usage:
+  aws-cis-scanner remediate -plan [-format shell|json] [-o file] results.json
+  aws-cis-scanner remediate -apply -allow 1.5,2.2,2.8,4.4 [-journal file] [-endpoint url] results.json
+  aws-cis-scanner remediate -rollback journal.json [-endpoint url]
+  aws-cis-scanner remediate -monitoring terraform|cloudformation -log-group name -topic arn [-o file]`
+
+/*
+runRemediate implements the 'remediate' command.  With -plan it writes the changes that would fix
+the open findings of a saved scan, for review.  With -apply it makes the allowed, reversible
+changes after confirming each one, and -rollback undoes them from the journal.  -monitoring
+writes a Terraform module or CloudFormation template for the section 3 filters and alarms.
+*/
+func runRemediate(args []string) {
+	var planPtr, applyPtr bool
+	var formatPtr, outputPtr, rollbackPtr, allowPtr, journalPtr, endpointPtr, regionPtr, configPtr string
+	var monitoringPtr, logGroupPtr, topicPtr string
+	fs := flag.NewFlagSet("remediate", flag.ExitOnError)
+	fs.BoolVar(&planPtr, "plan", false, "Write a plan of the AWS CLI commands that would fix each open finding.")
+	fs.StringVar(&formatPtr, "format", "shell", "Plan format, 'shell' or 'json'.")
+	fs.StringVar(&outputPtr, "o", "", "File to write the plan or template to.  Default is standard output.")
+	fs.BoolVar(&applyPtr, "apply", false, "Make the reversible changes for the checks in -allow, confirming each one.")
+	fs.StringVar(&allowPtr, "allow", "", "Comma separated list of checks -apply may fix, eg: '1.5,2.2,2.8,4.4' or '2.*'.")
+	fs.StringVar(&journalPtr, "journal", "", "File to record the changes made by -apply in.  Default is remediation-journal-<time>.json.")
+	fs.StringVar(&rollbackPtr, "rollback", "", "Undo the changes recorded in a journal written by -apply.")
+	fs.StringVar(&endpointPtr, "endpoint", "", "Send every AWS API call to this endpoint instead, eg: a local stand-in for AWS at 'http://localhost:4566'.")
+	fs.StringVar(&regionPtr, "region", regions.USEast1, "Region to use for global services such as IAM.")
+	fs.StringVar(&configPtr, "config", config.DefaultPath(), "Path to the configuration file, for the password policy thresholds.")
+	fs.StringVar(&monitoringPtr, "monitoring", "", "Write the section 3 metric filters and alarms as 'terraform' or 'cloudformation'.")
+	fs.StringVar(&logGroupPtr, "log-group", "", "Name or ARN of the CloudWatch Logs group the multi-region trail delivers to, for -monitoring.")
+	fs.StringVar(&topicPtr, "topic", "", "ARN of the SNS topic the alarms notify, for -monitoring.  It needs at least one subscriber.")
+	fs.Parse(args)
+
+	if monitoringPtr != "" {
+		if planPtr || applyPtr || rollbackPtr != "" || fs.NArg() != 0 || logGroupPtr == "" || topicPtr == "" {
+			fmt.Println(remediateUsage)
+			os.Exit(1)
+		}
+		writeMonitoring(monitoringPtr, logGroupPtr, topicPtr, outputPtr)
+		return
+	}
+
+	modes := 0
+	for _, set := range []bool{planPtr, applyPtr, rollbackPtr != ""} {
+		if set {
+			modes++
+		}
+	}
+	if modes != 1 || (rollbackPtr == "" && fs.NArg() != 1) || (rollbackPtr != "" && fs.NArg() != 0) {
+		fmt.Println(remediateUsage)
+		os.Exit(1)
+	}
+
+	switch {
+	case planPtr:
+		// The password policy commands in the plan use the configured thresholds
+		cfg, err := config.Load(configPtr)
+		if err != nil {
+			fmt.Println("Error loading configuration:", err)
+			os.Exit(1)
+		}
+		benchmark.Settings = cfg
+		writePlan(fs.Arg(0), formatPtr, outputPtr)
+	case applyPtr:
+		allow := splitList(allowPtr)
+		if len(allow) == 0 {
+			fmt.Println("-apply needs the checks it may fix listed with -allow, eg: -allow 1.5,2.2,2.8,4.4")
+			os.Exit(1)
+		}
+		cfg, err := config.Load(configPtr)
+		if err != nil {
+			fmt.Println("Error loading configuration:", err)
+			os.Exit(1)
+		}
+		if journalPtr == "" {
+			journalPtr = fmt.Sprintf("remediation-journal-%s.json", time.Now().Format("20060102-150405"))
+		}
+		applyPlan(fs.Arg(0), allow, journalPtr, newApplier(endpointPtr, regionPtr, cfg.Thresholds))
+	default:
+		rollback(rollbackPtr, newApplier(endpointPtr, regionPtr, config.Default().Thresholds))
+	}
+}
+
+func writePlan(results, format, output string) {
+	if format != "shell" && format != "json" {
+		fmt.Printf("Unknown plan format %q (expected \"shell\" or \"json\")\n", format)
+		os.Exit(1)
+	}
+	scan, err := readScan(results)
+	if err != nil {
+		fmt.Println("Error reading results:", err)
+		os.Exit(1)
+	}
+	plan := remediation.Build(scan, time.Now())
+
+	w := os.Stdout
+	if output != "" && output != "-" {
+		f, err := os.Create(output)
+		if err != nil {
+			fmt.Println("Error writing plan:", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		w = f
+	}
+	if format == "json" {
+		err = plan.WriteJSON(w)
+	} else {
+		err = plan.WriteShell(w)
+	}
+	if err != nil {
+		fmt.Println("Error writing plan:", err)
+		os.Exit(1)
+	}
+}
+
+func writeMonitoring(format, logGroup, topic, output string) {
+	w := os.Stdout
+	if output != "" && output != "-" {
+		f, err := os.Create(output)
+		if err != nil {
+			fmt.Println("Error writing template:", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := remediation.WriteMonitoring(w, format, logGroup, topic); err != nil {
+		fmt.Println("Error writing template:", err)
+		os.Exit(1)
+	}
+}
+
+func newApplier(endpoint, region string, thresholds config.Thresholds) *remediation.Applier {
+	sess, err := session.NewSession()
+	if err != nil {
+		panic(err)
+	}
+	return &remediation.Applier{Session: sess, Endpoint: endpoint, Region: region, Thresholds: thresholds}
+}
+
+/*
+checkAccount stops if the credentials in use are for a different account to the one the
+changes were planned or made for
+*/
+func checkAccount(a *remediation.Applier, account string) {
+	conf := aws.Config{Region: aws.String(a.Region)}
+	if a.Endpoint != "" {
+		conf.Endpoint = aws.String(a.Endpoint)
+	}
+	current, err := getAccountID(a.Session, conf)
+	if err != nil {
+		fmt.Println("Error identifying the account for the credentials in use (sts:GetCallerIdentity):", err)
+		os.Exit(1)
+	}
+	if current != account {
+		fmt.Printf("The credentials in use are for account %s, not %s.\n", current, account)
+		os.Exit(1)
+	}
+}
+
+/*
+applyPlan makes each allowed, reversible step of the plan for a scan, asking before every
+change.  The journal is saved after each change, so it is complete even if a later step fails.
+*/
+func applyPlan(results string, allow []string, journalPath string, a *remediation.Applier) {
+	scan, err := readScan(results)
+	if err != nil {
+		fmt.Println("Error reading results:", err)
+		os.Exit(1)
+	}
+	checkAccount(a, scan.Account)
+	plan := remediation.Build(scan, time.Now())
+	journal := remediation.NewJournal(journalPath, scan.Account)
+	in := bufio.NewReader(os.Stdin)
+
+	for _, s := range plan.Steps {
+		if !s.Allowed(allow) {
+			continue
+		}
+		if !remediation.Reversible[s.Action] {
+			fmt.Printf("Skipping finding %s: it can't be fixed automatically.  Use 'remediate -plan' instead.\n", s.Check)
+			continue
+		}
+		describeStep(s)
+		if !confirm(in, "Apply this change?") {
+			continue
+		}
+		c, err := a.Apply(s)
+		if !c.Applied.IsZero() {
+			journal.Changes = append(journal.Changes, c)
+			if err := journal.Save(); err != nil {
+				fmt.Println("Error saving journal, stopping:", err)
+				os.Exit(1)
+			}
+		}
+		if err != nil {
+			fmt.Printf("Error fixing finding %s: %s\n", s.Check, err)
+			continue
+		}
+		fmt.Println("Done.")
+	}
+	if len(journal.Changes) == 0 {
+		fmt.Println("No changes made.")
+		return
+	}
+	fmt.Printf("%d change(s) recorded in %s.  Undo them with 'aws-cis-scanner remediate -rollback %s'.\n", len(journal.Changes), journalPath, journalPath)
+}
+
+/*
+rollback undoes the changes in a journal, newest first, asking before each one
+*/
+func rollback(journalPath string, a *remediation.Applier) {
+	journal, err := remediation.LoadJournal(journalPath)
+	if err != nil {
+		fmt.Println("Error reading journal:", err)
+		os.Exit(1)
+	}
+	checkAccount(a, journal.Account)
+	in := bufio.NewReader(os.Stdin)
+
+	undone := 0
+	for i := len(journal.Changes) - 1; i >= 0; i-- {
+		c := journal.Changes[i]
+		if c.RolledBack != nil {
+			continue
+		}
+		fmt.Printf("\nUndo the change made %s for finding %s: %s\n", c.Applied.Format(time.RFC3339), c.Check, c.Title)
+		if c.Resource != "" {
+			fmt.Printf("  Resource: %s %s\n", c.Resource, c.Region)
+		}
+		if !confirm(in, "Roll back this change?") {
+			continue
+		}
+		if err := a.Rollback(c); err != nil {
+			fmt.Printf("Error rolling back finding %s: %s\n", c.Check, err)
+			continue
+		}
+		now := time.Now().UTC()
+		journal.Changes[i].RolledBack = &now
+		if err := journal.Save(); err != nil {
+			fmt.Println("Error saving journal, stopping:", err)
+			os.Exit(1)
+		}
+		undone++
+		fmt.Println("Done.")
+	}
+	fmt.Printf("%d change(s) rolled back.\n", undone)
+}
+
+func describeStep(s remediation.Step) {
+	fmt.Printf("\nFinding %s: %s\n", s.Check, s.Title)
+	if s.Resource != "" {
+		fmt.Printf("  Resource: %s %s\n", s.Resource, s.Region)
+	}
+	for _, c := range s.Commands {
+		fmt.Printf("  %s\n", c)
+	}
+}
+
+/*
+confirm asks a yes/no question on standard input.  Anything but yes is no.
+*/
+func confirm(in *bufio.Reader, question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := in.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,317 @@
+package remediation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/adamcrosby/aws-cis-scanner/utility/config"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudtrail"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/kms"
+)
+
+// Reversible lists the actions remediate -apply can make.  Each one is low risk and can be
+// undone from the values recorded in the journal.
+var Reversible = map[string]bool{
+	ActionPasswordPolicy:     true,
+	ActionLogFileValidation:  true,
+	ActionKeyRotation:        true,
+	ActionClearDefaultGroups: true,
+}
+
+// DefaultGroup holds the rules a default security group had before they were removed
+type DefaultGroup struct {
+	GroupID string
+	VpcID   string
+	Ingress []*ec2.IpPermission `json:",omitempty"`
+	Egress  []*ec2.IpPermission `json:",omitempty"`
+}
+
+// Change is a step that was applied, with the values it replaced.  Only the field for the
+// steps action is set.  A nil PasswordPolicy means the account had no password policy.
+type Change struct {
+	Step
+	Applied           time.Time
+	RolledBack        *time.Time          `json:",omitempty"`
+	PasswordPolicy    *iam.PasswordPolicy `json:",omitempty"`
+	LogFileValidation *bool               `json:",omitempty"`
+	KeyRotation       *bool               `json:",omitempty"`
+	DefaultGroups     []DefaultGroup      `json:",omitempty"`
+}
+
+// Journal is the record of the changes made by a run of remediate -apply
+type Journal struct {
+	path    string
+	Account string
+	Changes []Change
+}
+
+/*
+NewJournal starts an empty journal that will be saved to the given file
+*/
+func NewJournal(path, account string) *Journal {
+	return &Journal{path: path, Account: account}
+}
+
+/*
+LoadJournal reads a journal written by remediate -apply
+*/
+func LoadJournal(path string) (*Journal, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	j := &Journal{path: path}
+	if err := json.Unmarshal(b, j); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return j, nil
+}
+
+/*
+Save writes the journal, replacing the file in one step so an interrupted run never leaves
+a partial journal behind
+*/
+func (j *Journal) Save() error {
+	if err := os.MkdirAll(filepath.Dir(j.path), 0700); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := j.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, j.path)
+}
+
+// Applier makes and undoes changes.  Endpoint, if set, replaces the AWS endpoint for every
+// service, eg: to run against a local stand-in for AWS.  Region is used for global services.
+type Applier struct {
+	Session    *session.Session
+	Endpoint   string
+	Region     string
+	Thresholds config.Thresholds
+}
+
+func (a *Applier) config(region string) *aws.Config {
+	if region == "" {
+		region = a.Region
+	}
+	c := &aws.Config{Region: aws.String(region)}
+	if a.Endpoint != "" {
+		c.Endpoint = aws.String(a.Endpoint)
+	}
+	return c
+}
+
+/*
+Apply makes the change for a step.  The values it replaces are read first; if that fails nothing
+is changed and Applied is left unset.  Once Applied is set the change should be journaled, even
+if it returned an error, as part of it may have been made.
+*/
+func (a *Applier) Apply(s Step) (Change, error) {
+	c := Change{Step: s}
+	if err := a.record(&c); err != nil {
+		return c, err
+	}
+	c.Applied = time.Now().UTC()
+	return c, a.change(c)
+}
+
+/*
+record reads the values a step will replace into the change
+*/
+func (a *Applier) record(c *Change) error {
+	switch c.Action {
+	case ActionPasswordPolicy:
+		resp, err := iam.New(a.Session, a.config("")).GetAccountPasswordPolicy(&iam.GetAccountPasswordPolicyInput{})
+		if err != nil {
+			if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == iam.ErrCodeNoSuchEntityException {
+				return nil
+			}
+			return err
+		}
+		c.PasswordPolicy = resp.PasswordPolicy
+	case ActionLogFileValidation:
+		resp, err := cloudtrail.New(a.Session, a.config(c.Region)).DescribeTrails(&cloudtrail.DescribeTrailsInput{TrailNameList: []*string{aws.String(c.Resource)}})
+		if err != nil {
+			return err
+		}
+		if len(resp.TrailList) == 0 {
+			return fmt.Errorf("trail %s not found in %s", c.Resource, c.Region)
+		}
+		c.LogFileValidation = aws.Bool(aws.BoolValue(resp.TrailList[0].LogFileValidationEnabled))
+	case ActionKeyRotation:
+		resp, err := kms.New(a.Session, a.config(c.Region)).GetKeyRotationStatus(&kms.GetKeyRotationStatusInput{KeyId: aws.String(c.Resource)})
+		if err != nil {
+			return err
+		}
+		c.KeyRotation = aws.Bool(aws.BoolValue(resp.KeyRotationEnabled))
+	case ActionClearDefaultGroups:
+		in := &ec2.DescribeSecurityGroupsInput{
+			Filters: []*ec2.Filter{{Name: aws.String("group-name"), Values: []*string{aws.String("default")}}}}
+		// Older 4.4 findings name a region rather than a security group, so every default group
+		// in that region is recorded
+		if strings.HasPrefix(c.Resource, "sg-") {
+			in.GroupIds = []*string{aws.String(c.Resource)}
+		}
+		resp, err := ec2.New(a.Session, a.config(c.Region)).DescribeSecurityGroups(in)
+		if err != nil {
+			return err
+		}
+		for _, sg := range resp.SecurityGroups {
+			c.DefaultGroups = append(c.DefaultGroups, DefaultGroup{
+				GroupID: aws.StringValue(sg.GroupId),
+				VpcID:   aws.StringValue(sg.VpcId),
+				Ingress: sg.IpPermissions,
+				Egress:  sg.IpPermissionsEgress})
+		}
+	default:
+		return fmt.Errorf("finding %s can't be fixed automatically", c.Check)
+	}
+	return nil
+}
+
+/*
+change makes the change for a step, once the values it replaces have been recorded
+*/
+func (a *Applier) change(c Change) error {
+	switch c.Action {
+	case ActionPasswordPolicy:
+		_, err := iam.New(a.Session, a.config("")).UpdateAccountPasswordPolicy(policyInput(a.benchmarkPolicy(c.PasswordPolicy)))
+		return err
+	case ActionLogFileValidation:
+		_, err := cloudtrail.New(a.Session, a.config(c.Region)).UpdateTrail(&cloudtrail.UpdateTrailInput{
+			Name:                    aws.String(c.Resource),
+			EnableLogFileValidation: aws.Bool(true)})
+		return err
+	case ActionKeyRotation:
+		_, err := kms.New(a.Session, a.config(c.Region)).EnableKeyRotation(&kms.EnableKeyRotationInput{KeyId: aws.String(c.Resource)})
+		return err
+	case ActionClearDefaultGroups:
+		svc := ec2.New(a.Session, a.config(c.Region))
+		for _, g := range c.DefaultGroups {
+			if len(g.Ingress) > 0 {
+				if _, err := svc.RevokeSecurityGroupIngress(&ec2.RevokeSecurityGroupIngressInput{GroupId: aws.String(g.GroupID), IpPermissions: g.Ingress}); err != nil {
+					return err
+				}
+			}
+			if len(g.Egress) > 0 {
+				if _, err := svc.RevokeSecurityGroupEgress(&ec2.RevokeSecurityGroupEgressInput{GroupId: aws.String(g.GroupID), IpPermissions: g.Egress}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown action %q", c.Action)
+}
+
+/*
+Rollback puts back the values a change replaced
+*/
+func (a *Applier) Rollback(c Change) error {
+	switch c.Action {
+	case ActionPasswordPolicy:
+		svc := iam.New(a.Session, a.config(""))
+		if c.PasswordPolicy == nil {
+			_, err := svc.DeleteAccountPasswordPolicy(&iam.DeleteAccountPasswordPolicyInput{})
+			return err
+		}
+		_, err := svc.UpdateAccountPasswordPolicy(policyInput(*c.PasswordPolicy))
+		return err
+	case ActionLogFileValidation:
+		if c.LogFileValidation == nil || *c.LogFileValidation {
+			return nil
+		}
+		_, err := cloudtrail.New(a.Session, a.config(c.Region)).UpdateTrail(&cloudtrail.UpdateTrailInput{
+			Name:                    aws.String(c.Resource),
+			EnableLogFileValidation: aws.Bool(false)})
+		return err
+	case ActionKeyRotation:
+		if c.KeyRotation == nil || *c.KeyRotation {
+			return nil
+		}
+		_, err := kms.New(a.Session, a.config(c.Region)).DisableKeyRotation(&kms.DisableKeyRotationInput{KeyId: aws.String(c.Resource)})
+		return err
+	case ActionClearDefaultGroups:
+		svc := ec2.New(a.Session, a.config(c.Region))
+		for _, g := range c.DefaultGroups {
+			if len(g.Ingress) > 0 {
+				if _, err := svc.AuthorizeSecurityGroupIngress(&ec2.AuthorizeSecurityGroupIngressInput{GroupId: aws.String(g.GroupID), IpPermissions: g.Ingress}); err != nil && !duplicate(err) {
+					return err
+				}
+			}
+			if len(g.Egress) > 0 {
+				if _, err := svc.AuthorizeSecurityGroupEgress(&ec2.AuthorizeSecurityGroupEgressInput{GroupId: aws.String(g.GroupID), IpPermissions: g.Egress}); err != nil && !duplicate(err) {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown action %q", c.Action)
+}
+
+/*
+benchmarkPolicy raises a password policy to the thresholds, keeping any setting that is
+already stricter
+*/
+func (a *Applier) benchmarkPolicy(previous *iam.PasswordPolicy) iam.PasswordPolicy {
+	pp := iam.PasswordPolicy{}
+	if previous != nil {
+		pp = *previous
+	}
+	pp.RequireUppercaseCharacters = aws.Bool(true)
+	pp.RequireLowercaseCharacters = aws.Bool(true)
+	pp.RequireSymbols = aws.Bool(true)
+	pp.RequireNumbers = aws.Bool(true)
+	if aws.Int64Value(pp.MinimumPasswordLength) < int64(a.Thresholds.PasswordMinLength) {
+		pp.MinimumPasswordLength = aws.Int64(int64(a.Thresholds.PasswordMinLength))
+	}
+	if aws.Int64Value(pp.PasswordReusePrevention) < 24 {
+		pp.PasswordReusePrevention = aws.Int64(24)
+	}
+	if age := aws.Int64Value(pp.MaxPasswordAge); age == 0 || age > int64(a.Thresholds.PasswordMaxAgeDays) {
+		pp.MaxPasswordAge = aws.Int64(int64(a.Thresholds.PasswordMaxAgeDays))
+	}
+	return pp
+}
+
+// duplicate reports whether a rule being restored is already there, eg: when removing the rules
+// of a group failed part way through
+func duplicate(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	return ok && awsErr.Code() == "InvalidPermission.Duplicate"
+}
+
+func policyInput(pp iam.PasswordPolicy) *iam.UpdateAccountPasswordPolicyInput {
+	in := &iam.UpdateAccountPasswordPolicyInput{
+		AllowUsersToChangePassword: pp.AllowUsersToChangePassword,
+		HardExpiry:                 pp.HardExpiry,
+		MinimumPasswordLength:      pp.MinimumPasswordLength,
+		RequireLowercaseCharacters: pp.RequireLowercaseCharacters,
+		RequireNumbers:             pp.RequireNumbers,
+		RequireSymbols:             pp.RequireSymbols,
+		RequireUppercaseCharacters: pp.RequireUppercaseCharacters}
+	// Zero means "not set" in the policy, but isn't a valid value to send
+	if aws.Int64Value(pp.MaxPasswordAge) > 0 {
+		in.MaxPasswordAge = pp.MaxPasswordAge
+	}
+	if aws.Int64Value(pp.PasswordReusePrevention) > 0 {
+		in.PasswordReusePrevention = pp.PasswordReusePrevention
+	}
+	return in
+}
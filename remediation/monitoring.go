@@ -0,0 +1,156 @@
+package remediation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/adamcrosby/aws-cis-scanner/benchmark"
+)
+
+// Formats the section 3 metric filters and alarms can be generated in
+const (
+	FormatTerraform      = "terraform"
+	FormatCloudFormation = "cloudformation"
+)
+
+// alarm is a single filter and alarm for a section 3 check
+type alarm struct {
+	id      string
+	title   string
+	pattern string
+	metric  string
+}
+
+func monitoringAlarms() []alarm {
+	var alarms []alarm
+	for i := range benchmark.FilterPatterns {
+		id := fmt.Sprintf("3.%d", i+1)
+		c, _ := benchmark.Lookup(id)
+		alarms = append(alarms, alarm{id: id, title: c.Title, pattern: benchmark.FilterPatterns[i], metric: benchmark.MetricName(id)})
+	}
+	return alarms
+}
+
+/*
+LogGroupName returns the name of a log group from its name or ARN, eg:
+"arn:aws:logs:us-east-1:123456789012:log-group:CloudTrail/DefaultLogGroup:*"
+*/
+func LogGroupName(group string) string {
+	if strings.HasPrefix(group, "arn:") {
+		if parts := strings.Split(group, ":"); len(parts) > 6 {
+			return parts[6]
+		}
+	}
+	return group
+}
+
+/*
+WriteMonitoring writes a Terraform module or CloudFormation template creating a metric filter
+and alarm for each of 3.1 - 3.14 on the trail's log group, using the same patterns the checks
+look for.  Each alarm notifies the topic, which needs at least one subscriber for the checks
+to pass.
+*/
+func WriteMonitoring(w io.Writer, format, logGroup, topicARN string) error {
+	switch format {
+	case FormatTerraform:
+		return writeTerraform(w, LogGroupName(logGroup), topicARN)
+	case FormatCloudFormation:
+		return writeCloudFormation(w, LogGroupName(logGroup), topicARN)
+	}
+	return fmt.Errorf("unknown format %q (expected %q or %q)", format, FormatTerraform, FormatCloudFormation)
+}
+
+func writeTerraform(w io.Writer, logGroup, topicARN string) error {
+	var b strings.Builder
+	fmt.Fprintln(&b, "# CIS AWS Foundations Benchmark 3.1 - 3.14: metric filters and alarms on the CloudTrail log group")
+	fmt.Fprintln(&b, "# Generated by aws-cis-scanner.")
+	fmt.Fprintf(&b, "\nvariable \"log_group_name\" {\n  description = \"CloudWatch Logs group the multi-region trail delivers to\"\n  default     = %s\n}\n", hclString(logGroup))
+	fmt.Fprintf(&b, "\nvariable \"alarm_topic_arn\" {\n  description = \"SNS topic the alarms notify.  It needs at least one subscriber.\"\n  default     = %s\n}\n", hclString(topicARN))
+
+	for _, a := range monitoringAlarms() {
+		name := "cis_" + strings.Replace(a.id, ".", "_", -1)
+		fmt.Fprintf(&b, "\n# %s %s\n", a.id, a.title)
+		fmt.Fprintf(&b, "resource \"aws_cloudwatch_log_metric_filter\" %q {\n", name)
+		fmt.Fprintf(&b, "  name           = %s\n", hclString(a.metric))
+		fmt.Fprintf(&b, "  log_group_name = var.log_group_name\n")
+		fmt.Fprintf(&b, "  pattern        = %s\n\n", hclString(a.pattern))
+		fmt.Fprintf(&b, "  metric_transformation {\n    name      = %s\n    namespace = %s\n    value     = \"1\"\n  }\n}\n", hclString(a.metric), hclString(benchmark.MetricNamespace))
+
+		fmt.Fprintf(&b, "\nresource \"aws_cloudwatch_metric_alarm\" %q {\n", name)
+		fmt.Fprintf(&b, "  alarm_name          = %s\n", hclString(a.metric))
+		fmt.Fprintf(&b, "  alarm_description   = %s\n", hclString(a.title))
+		fmt.Fprintf(&b, "  metric_name         = aws_cloudwatch_log_metric_filter.%s.metric_transformation[0].name\n", name)
+		fmt.Fprintf(&b, "  namespace           = %s\n", hclString(benchmark.MetricNamespace))
+		fmt.Fprintln(&b, "  statistic           = \"Sum\"")
+		fmt.Fprintln(&b, "  period              = 300")
+		fmt.Fprintln(&b, "  evaluation_periods  = 1")
+		fmt.Fprintln(&b, "  threshold           = 1")
+		fmt.Fprintln(&b, "  comparison_operator = \"GreaterThanOrEqualToThreshold\"")
+		fmt.Fprintln(&b, "  treat_missing_data  = \"notBreaching\"")
+		fmt.Fprintln(&b, "  alarm_actions       = [var.alarm_topic_arn]")
+		fmt.Fprintln(&b, "}")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+/*
+hclString quotes a string for Terraform, escaping the sequences it would otherwise interpolate
+*/
+func hclString(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "${", "$${", "%{", "%%{").Replace(s)
+	return `"` + s + `"`
+}
+
+func writeCloudFormation(w io.Writer, logGroup, topicARN string) error {
+	resources := make(map[string]interface{})
+	for _, a := range monitoringAlarms() {
+		name := "CIS" + strings.Replace(a.id, ".", "", -1)
+		resources[name+"MetricFilter"] = map[string]interface{}{
+			"Type": "AWS::Logs::MetricFilter",
+			"Properties": map[string]interface{}{
+				"FilterName":    a.metric,
+				"LogGroupName":  map[string]string{"Ref": "LogGroupName"},
+				"FilterPattern": a.pattern,
+				"MetricTransformations": []map[string]string{{
+					"MetricName":      a.metric,
+					"MetricNamespace": benchmark.MetricNamespace,
+					"MetricValue":     "1"}}}}
+		resources[name+"Alarm"] = map[string]interface{}{
+			"Type":      "AWS::CloudWatch::Alarm",
+			"DependsOn": name + "MetricFilter",
+			"Properties": map[string]interface{}{
+				"AlarmName":          a.metric,
+				"AlarmDescription":   a.id + " " + a.title,
+				"MetricName":         a.metric,
+				"Namespace":          benchmark.MetricNamespace,
+				"Statistic":          "Sum",
+				"Period":             300,
+				"EvaluationPeriods":  1,
+				"Threshold":          1,
+				"ComparisonOperator": "GreaterThanOrEqualToThreshold",
+				"TreatMissingData":   "notBreaching",
+				"AlarmActions":       []interface{}{map[string]string{"Ref": "AlarmTopicArn"}}}}
+	}
+
+	template := map[string]interface{}{
+		"AWSTemplateFormatVersion": "2010-09-09",
+		"Description":              "CIS AWS Foundations Benchmark 3.1 - 3.14: metric filters and alarms on the CloudTrail log group.  Generated by aws-cis-scanner.",
+		"Parameters": map[string]interface{}{
+			"LogGroupName": map[string]string{
+				"Type":        "String",
+				"Description": "CloudWatch Logs group the multi-region trail delivers to",
+				"Default":     logGroup},
+			"AlarmTopicArn": map[string]string{
+				"Type":        "String",
+				"Description": "SNS topic the alarms notify.  It needs at least one subscriber.",
+				"Default":     topicARN}},
+		"Resources": resources}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(template)
+}
@@ -0,0 +1,190 @@
+package remediation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/adamcrosby/aws-cis-scanner/benchmark"
+	"github.com/adamcrosby/aws-cis-scanner/utility/findings"
+)
+
+// Actions are the API changes a step makes.  Steps without one have to be carried out by hand.
+const (
+	ActionPasswordPolicy     = "iam:UpdateAccountPasswordPolicy"
+	ActionLogFileValidation  = "cloudtrail:UpdateTrail"
+	ActionKeyRotation        = "kms:EnableKeyRotation"
+	ActionRevokeIngress      = "ec2:RevokeSecurityGroupIngress"
+	ActionClearDefaultGroups = "ec2:RevokeDefaultSecurityGroupRules"
+)
+
+// actions maps the checks that can be fixed with a single, well understood API change to it
+var actions = map[string]string{
+	"1.5":  ActionPasswordPolicy,
+	"1.6":  ActionPasswordPolicy,
+	"1.7":  ActionPasswordPolicy,
+	"1.8":  ActionPasswordPolicy,
+	"1.9":  ActionPasswordPolicy,
+	"1.10": ActionPasswordPolicy,
+	"1.11": ActionPasswordPolicy,
+	"2.2":  ActionLogFileValidation,
+	"2.8":  ActionKeyRotation,
+	"4.1":  ActionRevokeIngress,
+	"4.2":  ActionRevokeIngress,
+	"4.4":  ActionClearDefaultGroups,
+}
+
+// Step is a single change in a plan, fixing one finding for one resource (or the whole account)
+type Step struct {
+	Check    string
+	Title    string
+	Region   string   `json:",omitempty"`
+	Resource string   `json:",omitempty"`
+	Action   string   `json:",omitempty"`
+	Commands []string `json:",omitempty"`
+	Manual   []string `json:",omitempty"`
+}
+
+// Plan is the list of changes that would fix the open findings of a scan.  Nothing in it is run.
+type Plan struct {
+	Account   string
+	ScannedAt time.Time
+	Generated time.Time
+	Steps     []Step
+}
+
+/*
+Build creates the plan for the open findings of a scan.  Waived findings and resources are left
+alone.  Checks with an action get a step per failing resource; the rest get a step with the
+guidance commands for the whole finding.
+*/
+func Build(scan findings.Scan, now time.Time) Plan {
+	plan := Plan{Account: scan.Account, ScannedAt: scan.Timestamp, Generated: now}
+
+	var names []string
+	for name, f := range scan.Checks {
+		if f.Status.Open == findings.FindingOpen {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool { return findings.LessID(scan.Checks[names[i]].ID(), scan.Checks[names[j]].ID()) })
+
+	passwordPolicy := false
+	for _, name := range names {
+		f := scan.Checks[name]
+		action := actions[f.ID()]
+		open := f.OpenResources()
+
+		switch {
+		case action == ActionPasswordPolicy:
+			// One policy update fixes all of 1.5 - 1.11
+			if passwordPolicy {
+				continue
+			}
+			passwordPolicy = true
+			plan.Steps = append(plan.Steps, step(f, findings.Resource{}, action))
+		case action != "" && len(open) > 0:
+			for _, r := range open {
+				plan.Steps = append(plan.Steps, step(f, r, action))
+			}
+		default:
+			plan.Steps = append(plan.Steps, step(f, findings.Resource{}, ""))
+		}
+	}
+	return plan
+}
+
+/*
+Allowed reports whether the allowlist (check IDs or patterns, eg: "2.*") covers a step.  A
+step is covered by any of the checks its action fixes, so "1.9" allows the password policy
+update that also fixes 1.5 - 1.11.
+*/
+func (s Step) Allowed(allow []string) bool {
+	for _, p := range allow {
+		if ok, _ := path.Match(p, s.Check); ok {
+			return true
+		}
+		for id, action := range actions {
+			if ok, _ := path.Match(p, id); ok && s.Action != "" && action == s.Action {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func step(f findings.Finding, r findings.Resource, action string) Step {
+	if r.ID != "" {
+		f.Resources = []findings.Resource{r}
+	}
+	s := Step{Check: f.ID(), Title: f.Description, Region: r.Region, Resource: r.ID, Action: action}
+	if action == ActionPasswordPolicy {
+		s.Title = "Set the account password policy to the benchmark values (1.5 - 1.11)"
+	}
+	if g := benchmark.Remediation(f); g != nil {
+		s.Commands = g.CLI
+		s.Manual = g.Console
+	}
+	return s
+}
+
+/*
+WriteJSON writes the plan as JSON
+*/
+func (p Plan) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
+
+/*
+WriteShell writes the plan as a shell script for review.  Steps with values that still need
+filling in (eg: <role-arn>) are commented out, so the script can't run them by accident.
+*/
+func (p Plan) WriteShell(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintln(&b, "#!/bin/sh")
+	fmt.Fprintf(&b, "# Remediation plan for account %s, from the scan taken %s\n", p.Account, p.ScannedAt.Format(time.RFC3339))
+	fmt.Fprintf(&b, "# Generated %s.  Review every command before running it.\n", p.Generated.Format(time.RFC3339))
+	fmt.Fprintln(&b, "# Commands containing <placeholders> are commented out until the values are filled in.")
+	fmt.Fprintln(&b, "set -e")
+	if len(p.Steps) == 0 {
+		fmt.Fprintln(&b, "\n# No open findings, nothing to do.")
+	}
+
+	for _, s := range p.Steps {
+		fmt.Fprintf(&b, "\n# Finding %s: %s\n", s.Check, s.Title)
+		if s.Resource != "" {
+			fmt.Fprintf(&b, "# Resource: %s", s.Resource)
+			if s.Region != "" {
+				fmt.Fprintf(&b, " (%s)", s.Region)
+			}
+			fmt.Fprintln(&b)
+		}
+		if len(s.Commands) == 0 {
+			fmt.Fprintln(&b, "# This can't be fixed from the CLI.  In the console:")
+			for i, m := range s.Manual {
+				fmt.Fprintf(&b, "#   %d. %s\n", i+1, m)
+			}
+			continue
+		}
+		// A step is run as a whole or not at all, so one placeholder comments out every command in it
+		prefix := ""
+		for _, c := range s.Commands {
+			if strings.Contains(c, "<") && strings.Contains(c, ">") {
+				fmt.Fprintln(&b, "# Fill in the <values> below, then uncomment:")
+				prefix = "# "
+				break
+			}
+		}
+		for _, c := range s.Commands {
+			fmt.Fprintf(&b, "%s%s\n", prefix, c)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/adamcrosby/aws-cis-scanner/benchmark"
+	"github.com/adamcrosby/aws-cis-scanner/utility/config"
+	"github.com/adamcrosby/aws-cis-scanner/utility/findings"
+	"github.com/adamcrosby/aws-cis-scanner/utility/history"
+	"github.com/adamcrosby/aws-cis-scanner/utility/regions"
+	"github.com/adamcrosby/aws-cis-scanner/utility/report"
+	"github.com/adamcrosby/aws-cis-scanner/utility/waivers"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/account"
+	"github.com/aws/aws-sdk-go/service/cloudtrail"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/configservice"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3control"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sts"
+)
+
+/*
+runScan implements the 'scan' command: run the selected checks, record the scan in the history
+store and write the results to each output
+*/
+func runScan(args []string) {
+	var regionPtr, historyPtr, waiversPtr, configPtr, formatPtr, outputPtr string
+	var checksPtr, skipPtr, sectionsPtr string
+	const (
+		defaultRegion     = regions.AllRegions
+		regionFlagUsage   = "AWS Region in standard shorthand format (eg: 'us-east-1' or 'us-west-2').  Default is \"us-east-1\"."
+		historyFlagUsage  = "Path to the scan history store."
+		waiversFlagUsage  = "Path to a JSON waiver file of accepted risks."
+		configFlagUsage   = "Path to the configuration file."
+		formatFlagUsage   = "Output format, 'html' or 'json'.  Replaces the outputs in the configuration file."
+		outputFlagUsage   = "File to write the output to.  Default is standard output."
+		checksFlagUsage   = "Comma separated list of checks to run, eg: '2.1,2.2,4.1' or '3.*'.  Default is every check."
+		skipFlagUsage     = "Comma separated list of checks to skip, eg: '3.*'."
+		sectionsFlagUsage = "Comma separated list of sections to run, eg: '1,4'.  Default is every section."
+	)
+	fs := flag.NewFlagSet("scan", flag.ExitOnError)
+	fs.StringVar(&regionPtr, "region", defaultRegion, regionFlagUsage)
+	fs.StringVar(&regionPtr, "r", defaultRegion, regionFlagUsage+" (shorthand)")
+	fs.StringVar(&historyPtr, "history", history.DefaultPath(), historyFlagUsage)
+	fs.StringVar(&waiversPtr, "waivers", "", waiversFlagUsage)
+	fs.StringVar(&configPtr, "config", config.DefaultPath(), configFlagUsage)
+	fs.StringVar(&formatPtr, "format", config.FormatHTML, formatFlagUsage)
+	fs.StringVar(&outputPtr, "o", "", outputFlagUsage)
+	fs.StringVar(&checksPtr, "checks", "", checksFlagUsage)
+	fs.StringVar(&skipPtr, "skip", "", skipFlagUsage)
+	fs.StringVar(&sectionsPtr, "sections", "", sectionsFlagUsage)
+	fs.Parse(args)
+
+	cfg, err := config.Load(configPtr)
+	if err != nil {
+		fmt.Println("Error loading configuration:", err)
+		os.Exit(1)
+	}
+
+	// Command line flags override the configuration file
+	regionSet := false
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "region", "r":
+			regionSet = true
+		case "history":
+			cfg.History = historyPtr
+		case "waivers":
+			cfg.Waivers = waiversPtr
+		case "format", "o":
+			cfg.Outputs = []config.Output{{Format: formatPtr, Path: outputPtr}}
+		case "checks":
+			cfg.Checks.Enable = splitList(checksPtr)
+		case "skip":
+			cfg.Checks.Disable = splitList(skipPtr)
+		case "sections":
+			cfg.Sections.Enable = splitList(sectionsPtr)
+		}
+	})
+	if cfg.History == "" {
+		cfg.History = history.DefaultPath()
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Println("Error in configuration:", err)
+		os.Exit(1)
+	}
+	if err := benchmark.ValidatePatterns(append(cfg.Checks.Enable, cfg.Checks.Disable...)); err != nil {
+		fmt.Println("Error in check selection:", err)
+		os.Exit(1)
+	}
+	benchmark.Settings = cfg
+
+	var waiverList []waivers.Waiver
+	if cfg.Waivers != "" {
+		waiverList, err = waivers.Load(cfg.Waivers)
+		if err != nil {
+			fmt.Println("Error loading waivers:", err)
+			os.Exit(1)
+		}
+	}
+
+	var regionsList []string
+
+	switch regionPtr {
+	case regions.CNNorth1:
+		// if region is govcloud or china, special handling:
+		fmt.Println("Isolated Regions (CN) not yet supported.")
+		fmt.Println("If you need this support, please open a github issue.")
+		os.Exit(1)
+	case regions.GovCloud:
+		regionsList = regions.GovRegions
+	case regions.AllRegions:
+		regionsList = regions.CommercialRegions
+	default:
+		// a region was actually specified in the config, so use it.
+		regionsList = []string{regionPtr}
+	}
+	if !regionSet {
+		regionsList = cfg.SelectRegions(regionsList)
+	}
+	if len(regionsList) == 0 {
+		fmt.Println("No regions left to scan after applying the configuration.")
+		os.Exit(1)
+	}
+
+	// Create a new session
+	sess, err := session.NewSession()
+	if err != nil {
+		panic(err)
+	}
+
+	account, err := getAccountID(sess, aws.Config{Region: aws.String(regionsList[0])})
+	if err != nil {
+		fmt.Println("Error identifying the account for the credentials in use (sts:GetCallerIdentity):", err)
+		os.Exit(1)
+	}
+
+	scan := findings.Scan{
+		Account:          account,
+		Timestamp:        time.Now().UTC(),
+		BenchmarkVersion: findings.BenchmarkVersion,
+		Regions:          regionsList}
+
+	benchmark.Account = scan.Account
+	checks := make(findings.Checks, findings.FindingsInCISBenchmark)
+	trails := benchmark.NewTrails()
+	monitoring := benchmark.NewMonitoring()
+
+	for i := range regionsList {
+		conf := aws.Config{Region: aws.String(regionsList[i])}
+		// IAM is global, so only run section 1 once rather than regenerating the credential report in every region
+		checks = checkRegion(checks, trails, monitoring, sess, conf, i == 0)
+	}
+	checks = waivers.Apply(checks, waiverList, scan.Timestamp)
+	checks.Stamp(scan.Account)
+	benchmark.AddRemediation(checks)
+	scan.Checks = checks
+
+	// Record the scan so trends can be reported on, but don't lose the report if the store is unusable
+	store, err := history.Open(cfg.History)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error opening scan history:", err)
+		store = &history.Store{}
+	} else {
+		store.Add(scan)
+		if err := store.Save(); err != nil {
+			fmt.Fprintln(os.Stderr, "Error saving scan history:", err)
+		}
+	}
+
+	data := report.Data{Checks: scan.Checks, Trend: store.Trend(scan.Account, scan.Timestamp)}
+	for _, o := range cfg.Outputs {
+		if err := writeOutput(o, scan, data); err != nil {
+			fmt.Fprintln(os.Stderr, "Error writing output:", err)
+			os.Exit(1)
+		}
+	}
+}
+
+/*
+getAccountID returns the ID of the account the credentials in use belong to
+*/
+func getAccountID(sess *session.Session, conf aws.Config) (string, error) {
+	identity, err := sts.New(sess, &conf).GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(identity.Account), nil
+}
+
+func checkRegion(checks findings.Checks, trails *benchmark.Trails, monitoring *benchmark.Monitoring, sess *session.Session, conf aws.Config, global bool) findings.Checks {
+	// Only create clients for services a selected check uses.  The checks never touch a nil client.
+	var iamSvc *iam.IAM
+	var ctSvc *cloudtrail.CloudTrail
+	var s3Svc *s3.S3
+	var s3ctlSvc *s3control.S3Control
+	var cfSvc *configservice.ConfigService
+	var kmsKeys *benchmark.Keys
+	var cwlogsSvc *cloudwatchlogs.CloudWatchLogs
+	var cwSvc *cloudwatch.CloudWatch
+	var snsSvc *sns.SNS
+	var ec2Svc *ec2.EC2
+
+	if benchmark.ServiceNeeded(benchmark.ServiceIAM) && global {
+		iamSvc = iam.New(sess, &conf)
+		checks = benchmark.DoIAMChecks(iamSvc, checks)
+	}
+
+	// Setup for the Logging section of checks (2.1 - 2.8)
+	if benchmark.ServiceNeeded(benchmark.ServiceCloudTrail) {
+		ctSvc = cloudtrail.New(sess, &conf)
+	}
+	if benchmark.ServiceNeeded(benchmark.ServiceS3) {
+		s3Svc = s3.New(sess, &conf)
+		s3ctlSvc = s3control.New(sess, &conf)
+	}
+	if benchmark.ServiceNeeded(benchmark.ServiceConfig) {
+		cfSvc = configservice.New(sess, &conf)
+	}
+	if benchmark.ServiceNeeded(benchmark.ServiceKMS) {
+		// The customer managed keys are listed once per region, for both 2.8 and 5.6 - 5.8
+		kmsKeys = benchmark.NewKeys(kms.New(sess, &conf))
+		checks = benchmark.KMSChecks(kmsKeys, checks)
+	}
+	checks = benchmark.LoggingChecks(kmsKeys, cfSvc, s3Svc, s3ctlSvc, ctSvc, trails, checks)
+	if s3Svc != nil && global {
+		// Buckets are listed account wide, so the S3 checks only run once
+		checks = benchmark.S3Checks(s3Svc, s3ctlSvc, checks)
+	}
+
+	if benchmark.ServiceNeeded(benchmark.ServiceCloudWatchLogs) {
+		cwlogsSvc = cloudwatchlogs.New(sess, &conf)
+	}
+	if benchmark.ServiceNeeded(benchmark.ServiceCloudWatch) {
+		cwSvc = cloudwatch.New(sess, &conf)
+	}
+	if benchmark.ServiceNeeded(benchmark.ServiceSNS) {
+		snsSvc = sns.New(sess, &conf)
+		checks = benchmark.SNSChecks(snsSvc, checks)
+	}
+	checks = benchmark.MonitoringChecks(snsSvc, cwSvc, cwlogsSvc, ctSvc, trails, monitoring, checks)
+	if benchmark.ServiceNeeded(benchmark.ServiceAccount) && global {
+		// Contacts belong to the account rather than a region
+		checks = benchmark.ContactChecks(account.New(sess, &conf), checks)
+	}
+
+	if benchmark.ServiceNeeded(benchmark.ServiceEC2) {
+		ec2Svc = ec2.New(sess, &conf)
+		checks = benchmark.DoNetworkChecks(ec2Svc, checks)
+	}
+
+	return checks
+}
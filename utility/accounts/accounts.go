@@ -8,7 +8,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/adamcrosby/aws-cis-scanner/utility/findings"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/iam"
@@ -119,25 +118,21 @@ func GetPasswordPolicy(IAM *iam.IAM) iam.PasswordPolicy {
 }
 
 /*
-UserPoliciesExist determines if any user policies exist
-Check 1.15
+UsersWithPolicies returns the names of the users that have inline or managed policies attached directly
 */
-func UserPoliciesExist(a []Account, IAM *iam.IAM) string {
-	resp := true // default to pass, and only overide it on inspection below
+func UsersWithPolicies(a []Account, IAM *iam.IAM) []string {
+	var users []string
 	for i := range a {
 		// get policies for each ARN
 		if a[i]["user"] == "<root_account>" {
 			// if User is '<root_account>' skip it - root can't have policies attached
 			continue
 		}
-		resp = checkInlinePolicies(a[i], IAM)
-		resp = checkManagedPolicies(a[i], IAM)
-	}
-	if resp {
-		return findings.FindingClosed
+		if !checkInlinePolicies(a[i], IAM) || !checkManagedPolicies(a[i], IAM) {
+			users = append(users, a[i]["user"])
+		}
 	}
-	return findings.FindingOpen
-
+	return users
 }
 
 func checkInlinePolicies(a Account, IAM *iam.IAM) bool {
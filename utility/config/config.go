@@ -0,0 +1,192 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FileName is the name of the configuration file in the scanners state directory
+const FileName = "config.json"
+
+// FormatHTML renders the HTML report
+const FormatHTML = "html"
+
+// FormatJSON writes the scan results as JSON
+const FormatJSON = "json"
+
+// Thresholds holds the limits the checks are measured against.  The defaults are the benchmark values.
+type Thresholds struct {
+	RootUseDays           int    `json:"root_use_days"`            // 1.1
+	CredentialUnusedDays  int    `json:"credential_unused_days"`   // 1.3
+	AccessKeyRotationDays int    `json:"access_key_rotation_days"` // 1.4
+	PasswordMinLength     int    `json:"password_min_length"`      // 1.9
+	PasswordMaxAgeDays    int    `json:"password_max_age_days"`    // 1.11
+	FlowLogTrafficType    string `json:"flow_log_traffic_type"`    // 4.3, REJECT (REJECT or ALL pass) or ALL
+}
+
+// Selection enables and disables checks or sections.  An empty Enable list means everything
+// is enabled.  Entries may be patterns, eg: "3.*".
+type Selection struct {
+	Enable  []string `json:"enable,omitempty"`
+	Disable []string `json:"disable,omitempty"`
+}
+
+// Regions lists the regions to scan.  An empty Include list means the default set for the partition.
+type Regions struct {
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// Output is a single place results are written to.  An empty path or "-" is standard output.
+type Output struct {
+	Format string `json:"format"`
+	Path   string `json:"path,omitempty"`
+}
+
+// Config holds everything that can be set in the configuration file
+type Config struct {
+	Thresholds Thresholds `json:"thresholds"`
+	Checks     Selection  `json:"checks"`
+	Sections   Selection  `json:"sections"`
+	Regions    Regions    `json:"regions"`
+	Outputs    []Output   `json:"outputs,omitempty"`
+	Waivers    string     `json:"waivers,omitempty"`
+	History    string     `json:"history,omitempty"`
+	// TrustedAccounts are other AWS accounts resources may be shared with, eg: a central logging
+	// account.  Access from any other account is reported as cross-account exposure.
+	TrustedAccounts []string `json:"trusted_accounts,omitempty"`
+	// SubscriberDomains are the email domains and HTTP(S) hosts SNS topics may deliver to (3.16).
+	// Subdomains are included, so "example.com" also allows "alerts.example.com".
+	SubscriberDomains []string `json:"subscriber_domains,omitempty"`
+}
+
+/*
+Default returns the configuration used when there's no file: benchmark thresholds, every
+check enabled and the HTML report on standard output
+*/
+func Default() Config {
+	return Config{
+		Thresholds: Thresholds{
+			RootUseDays:           30,
+			CredentialUnusedDays:  90,
+			AccessKeyRotationDays: 90,
+			PasswordMinLength:     14,
+			PasswordMaxAgeDays:    90,
+			FlowLogTrafficType:    "REJECT"},
+		Outputs: []Output{{Format: FormatHTML}}}
+}
+
+/*
+DefaultPath returns the location of the configuration file in the users home directory
+*/
+func DefaultPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = "."
+	}
+	return filepath.Join(home, ".aws-cis-scanner", FileName)
+}
+
+/*
+Load reads a configuration file over the defaults, so only the values being changed need to
+be in it.  If the file is the default one and doesn't exist, the defaults are returned.
+*/
+func Load(filename string) (Config, error) {
+	c := Default()
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) && filename == DefaultPath() {
+			return c, nil
+		}
+		return c, err
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, fmt.Errorf("%s: %s", filename, err)
+	}
+	return c, c.Validate()
+}
+
+/*
+Validate checks the configuration for values the scanner can't use
+*/
+func (c Config) Validate() error {
+	t := c.Thresholds
+	if t.RootUseDays <= 0 || t.CredentialUnusedDays <= 0 || t.AccessKeyRotationDays <= 0 || t.PasswordMinLength <= 0 || t.PasswordMaxAgeDays <= 0 {
+		return fmt.Errorf("thresholds must all be greater than zero")
+	}
+	if t.FlowLogTrafficType != "REJECT" && t.FlowLogTrafficType != "ALL" {
+		return fmt.Errorf("flow_log_traffic_type must be %q or %q", "REJECT", "ALL")
+	}
+	for _, o := range c.Outputs {
+		if o.Format != FormatHTML && o.Format != FormatJSON {
+			return fmt.Errorf("unknown output format %q (expected %q or %q)", o.Format, FormatHTML, FormatJSON)
+		}
+	}
+	for _, a := range c.TrustedAccounts {
+		if len(a) != 12 || strings.Trim(a, "0123456789") != "" {
+			return fmt.Errorf("trusted account %q is not a 12 digit account ID", a)
+		}
+	}
+	for _, d := range c.SubscriberDomains {
+		if d == "" || strings.ContainsAny(d, "@/: ") {
+			return fmt.Errorf("subscriber domain %q is not a domain name, eg: example.com", d)
+		}
+	}
+	for _, list := range [][]string{c.Checks.Enable, c.Checks.Disable, c.Sections.Enable, c.Sections.Disable} {
+		for _, p := range list {
+			if _, err := path.Match(p, ""); err != nil {
+				return fmt.Errorf("invalid check pattern %q", p)
+			}
+		}
+	}
+	return nil
+}
+
+/*
+CheckEnabled reports whether a check (eg: "2.7") should be run.  Disabling wins over enabling,
+so "-sections 3" with "3.16" disabled runs the rest of section 3.
+*/
+func (c Config) CheckEnabled(id string) bool {
+	section := strings.SplitN(id, ".", 2)[0]
+	if len(c.Sections.Enable) > 0 && !matchAny(c.Sections.Enable, section) {
+		return false
+	}
+	if matchAny(c.Sections.Disable, section) {
+		return false
+	}
+	if len(c.Checks.Enable) > 0 && !matchAny(c.Checks.Enable, id) {
+		return false
+	}
+	return !matchAny(c.Checks.Disable, id)
+}
+
+/*
+SelectRegions applies the include and exclude lists to the default regions for the partition
+*/
+func (c Config) SelectRegions(defaults []string) []string {
+	include := defaults
+	if len(c.Regions.Include) > 0 {
+		include = c.Regions.Include
+	}
+	var selected []string
+	for _, r := range include {
+		if !matchAny(c.Regions.Exclude, r) {
+			selected = append(selected, r)
+		}
+	}
+	return selected
+}
+
+func matchAny(patterns []string, s string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, s); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,371 @@
+package filterpattern
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Term is a single comparison in a pattern, eg: $.eventName = CreateTrail.  Unary tests are
+// held in Op with an empty Value, eg: "IS TRUE" or "NOT EXISTS".
+type Term struct {
+	Selector string
+	Op       string
+	Value    string
+}
+
+// String returns the term in a normalized form, quoting values that need it
+func (t Term) String() string {
+	if t.Value == "" && (strings.HasPrefix(t.Op, "IS ") || strings.HasSuffix(t.Op, "EXISTS")) {
+		return t.Selector + " " + t.Op
+	}
+	v := t.Value
+	if strings.ContainsAny(v, " \t\"(){}&|=!<>") || v == "" {
+		v = `"` + strings.Replace(v, `"`, `\"`, -1) + `"`
+	}
+	return t.Selector + " " + t.Op + " " + v
+}
+
+// Expr is a node of a parsed pattern: either a single term, or the "&&" or "||" of its operands
+type Expr struct {
+	Op       string
+	Operands []*Expr
+	Term     *Term
+}
+
+/*
+String returns the expression in a normalized form: single spaces, no redundant parentheses and
+operands in sorted order, so two patterns that differ only in layout have the same string
+*/
+func (e *Expr) String() string {
+	if e.Term != nil {
+		return e.Term.String()
+	}
+	var parts []string
+	for _, o := range e.Operands {
+		s := o.String()
+		if o.Term == nil {
+			s = "(" + s + ")"
+		}
+		parts = append(parts, s)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, " "+e.Op+" ")
+}
+
+/*
+DNF returns the expression as an "||" of "&&" terms, eg: a && (b || c) is [[a b] [a c]].  An
+event matches the expression when it matches every term of at least one of the conjunctions.
+*/
+func (e *Expr) DNF() [][]Term {
+	if e.Term != nil {
+		return [][]Term{{*e.Term}}
+	}
+	if e.Op == "||" {
+		var out [][]Term
+		for _, o := range e.Operands {
+			out = append(out, o.DNF()...)
+		}
+		return out
+	}
+	out := [][]Term{{}}
+	for _, o := range e.Operands {
+		var next [][]Term
+		for _, left := range out {
+			for _, right := range o.DNF() {
+				next = append(next, append(append([]Term{}, left...), right...))
+			}
+		}
+		out = next
+	}
+	return out
+}
+
+/*
+Missing returns the parts of the required pattern a candidate doesn't match, as normalized
+conjunctions.  A candidate matches a required conjunction when one of its own conjunctions asks
+for nothing more, so a filter that matches more events than required (eg: extra "||" terms, or
+fewer "&&" conditions) still matches.  An empty result means every event the required pattern
+matches is matched by the candidate.
+*/
+func Missing(required, candidate *Expr) []string {
+	have := candidate.DNF()
+	var missing []string
+	for _, r := range required.DNF() {
+		covered := false
+		for _, c := range have {
+			if conjunctionCovers(c, r) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			missing = append(missing, conjunctionString(r))
+		}
+	}
+	return missing
+}
+
+// conjunctionCovers reports whether every term the candidate asks for is implied by the required terms
+func conjunctionCovers(candidate, required []Term) bool {
+	for _, c := range candidate {
+		implied := false
+		for _, r := range required {
+			if termCovers(c, r) {
+				implied = true
+				break
+			}
+		}
+		if !implied {
+			return false
+		}
+	}
+	return true
+}
+
+// termCovers reports whether an event matching r always matches c.  An "=" with a wildcard
+// matches the values its pattern covers, eg: "*Operation" covers "*UnauthorizedOperation".
+func termCovers(c, r Term) bool {
+	if c.Selector != r.Selector || c.Op != r.Op {
+		return false
+	}
+	if c.Value == r.Value {
+		return true
+	}
+	if c.Op == "=" && strings.Contains(c.Value, "*") {
+		return wildcardMatch(c.Value, r.Value)
+	}
+	return false
+}
+
+/*
+wildcardMatch reports whether s matches a filter pattern value, where "*" matches any run of
+characters (including "/" and none at all) and every other character only matches itself.  A "*"
+in s is taken literally, which is enough: only a "*" in the pattern can match it, and that would
+match whatever s's "*" stands for too.
+*/
+func wildcardMatch(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+	return strings.HasSuffix(s, parts[len(parts)-1])
+}
+
+func conjunctionString(terms []Term) string {
+	var parts []string
+	for _, t := range terms {
+		parts = append(parts, t.String())
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, " && ")
+}
+
+/*
+Parse parses a CloudWatch Logs JSON filter pattern, eg:
+{ ($.eventName = CreateTrail) || ($.eventName = "DeleteTrail") }
+*/
+func Parse(pattern string) (*Expr, error) {
+	tokens, err := lex(pattern)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	if !p.accept("{") {
+		return nil, fmt.Errorf("pattern must start with {")
+	}
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept("}") {
+		return nil, fmt.Errorf("expected } at %q", p.rest())
+	}
+	if p.pos != len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q after the closing }", p.rest())
+	}
+	return e, nil
+}
+
+// token is a lexed piece of a pattern.  Quoted strings have quoted set and are unescaped.
+type token struct {
+	text   string
+	quoted bool
+}
+
+func lex(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.IndexByte("{}()", c) >= 0:
+			tokens = append(tokens, token{text: string(c)})
+			i++
+		case strings.HasPrefix(s[i:], "&&") || strings.HasPrefix(s[i:], "||") || strings.HasPrefix(s[i:], "!=") || strings.HasPrefix(s[i:], "<=") || strings.HasPrefix(s[i:], ">="):
+			tokens = append(tokens, token{text: s[i : i+2]})
+			i += 2
+		case c == '=' || c == '<' || c == '>':
+			tokens = append(tokens, token{text: string(c)})
+			i++
+		case c == '"':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' && j+1 < len(s) {
+					j++
+				}
+				b.WriteByte(s[j])
+			}
+			if j == len(s) {
+				return nil, fmt.Errorf("unterminated string at %q", s[i:])
+			}
+			tokens = append(tokens, token{text: b.String(), quoted: true})
+			i = j + 1
+		default:
+			j := i
+			for j < len(s) && strings.IndexByte(" \t\n\r{}()=!<>\"", s[j]) < 0 && !strings.HasPrefix(s[j:], "&&") && !strings.HasPrefix(s[j:], "||") {
+				j++
+			}
+			if j == i {
+				return nil, fmt.Errorf("unexpected %q", s[i:])
+			}
+			tokens = append(tokens, token{text: s[i:j]})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos], true
+	}
+	return token{}, false
+}
+
+// accept consumes the next token if it is the given unquoted text
+func (p *parser) accept(text string) bool {
+	if t, ok := p.peek(); ok && !t.quoted && t.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) rest() string {
+	var parts []string
+	for _, t := range p.tokens[p.pos:] {
+		parts = append(parts, t.text)
+	}
+	return strings.Join(parts, " ")
+}
+
+func (p *parser) or() (*Expr, error) {
+	return p.binary("||", p.and)
+}
+
+func (p *parser) and() (*Expr, error) {
+	return p.binary("&&", p.unary)
+}
+
+// binary parses operands joined by op, flattening nested uses of the same operator
+func (p *parser) binary(op string, operand func() (*Expr, error)) (*Expr, error) {
+	e := &Expr{Op: op}
+	for {
+		o, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		if o.Op == op {
+			e.Operands = append(e.Operands, o.Operands...)
+		} else {
+			e.Operands = append(e.Operands, o)
+		}
+		if !p.accept(op) {
+			break
+		}
+	}
+	if len(e.Operands) == 1 {
+		return e.Operands[0], nil
+	}
+	return e, nil
+}
+
+func (p *parser) unary() (*Expr, error) {
+	if p.accept("(") {
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, fmt.Errorf("expected ) at %q", p.rest())
+		}
+		return e, nil
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() (*Expr, error) {
+	sel, ok := p.peek()
+	if !ok || sel.quoted || !strings.HasPrefix(sel.text, "$") {
+		return nil, fmt.Errorf("expected a selector such as $.eventName at %q", p.rest())
+	}
+	p.pos++
+	t := &Term{Selector: sel.text}
+
+	switch {
+	case p.accept("IS"):
+		v, ok := p.peek()
+		if !ok || v.quoted || (v.text != "TRUE" && v.text != "FALSE" && v.text != "NULL") {
+			return nil, fmt.Errorf("expected TRUE, FALSE or NULL after IS at %q", p.rest())
+		}
+		p.pos++
+		t.Op = "IS " + v.text
+	case p.accept("NOT"):
+		if !p.accept("EXISTS") {
+			return nil, fmt.Errorf("expected EXISTS after NOT at %q", p.rest())
+		}
+		t.Op = "NOT EXISTS"
+	case p.accept("EXISTS"):
+		t.Op = "EXISTS"
+	default:
+		op, ok := p.peek()
+		if !ok || op.quoted || !isOperator(op.text) {
+			return nil, fmt.Errorf("expected a comparison after %s at %q", sel.text, p.rest())
+		}
+		p.pos++
+		v, ok := p.peek()
+		if !ok || (!v.quoted && strings.IndexAny(v.text, "{}()") >= 0) || (!v.quoted && (isOperator(v.text) || v.text == "&&" || v.text == "||")) {
+			return nil, fmt.Errorf("expected a value after %s %s at %q", sel.text, op.text, p.rest())
+		}
+		p.pos++
+		t.Op, t.Value = op.text, v.text
+	}
+	return &Expr{Term: t}, nil
+}
+
+func isOperator(s string) bool {
+	switch s {
+	case "=", "!=", "<", ">", "<=", ">=":
+		return true
+	}
+	return false
+}
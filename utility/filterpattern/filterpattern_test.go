@@ -0,0 +1,147 @@
+package filterpattern_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/adamcrosby/aws-cis-scanner/benchmark"
+	"github.com/adamcrosby/aws-cis-scanner/utility/filterpattern"
+)
+
+func mustParse(t *testing.T, pattern string) *filterpattern.Expr {
+	t.Helper()
+	e, err := filterpattern.Parse(pattern)
+	if err != nil {
+		t.Fatalf("Parse(%q): %s", pattern, err)
+	}
+	return e
+}
+
+func TestBuiltInPatternsParse(t *testing.T) {
+	for i, pattern := range benchmark.FilterPatterns {
+		if _, err := filterpattern.Parse(pattern); err != nil {
+			t.Errorf("3.%d: Parse(%q): %s", i+1, pattern, err)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, pattern := range []string{
+		``,
+		`$.eventName = CreateTrail`,
+		`{ $.eventName = CreateTrail`,
+		`{ $.eventName = CreateTrail }}`,
+		`{ ($.eventName = CreateTrail }`,
+		`{ $.eventName = "CreateTrail }`,
+		`{ $.eventName = CreateTrail && }`,
+		`CreateTrail DeleteTrail`,
+	} {
+		if _, err := filterpattern.Parse(pattern); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", pattern)
+		}
+	}
+}
+
+// Patterns that only differ in layout, term order or quoting match the same events
+func TestLayoutCovers(t *testing.T) {
+	tests := []struct {
+		original, candidate string
+	}{
+		{`{ ($.eventName = CreateTrail) || ($.eventName = DeleteTrail) }`,
+			`{($.eventName=DeleteTrail)||($.eventName=CreateTrail)}`},
+		{`{ ($.eventName = CreateTrail) || ($.eventName = DeleteTrail) }`,
+			`{ $.eventName = "DeleteTrail" || $.eventName = "CreateTrail" }`},
+		{`{($.eventSource = kms.amazonaws.com) && (($.eventName=DisableKey)||($.eventName=ScheduleKeyDeletion))}`,
+			`{ (($.eventName = ScheduleKeyDeletion) || ($.eventName = DisableKey)) && ($.eventSource = kms.amazonaws.com) }`},
+		{`{ $.userIdentity.type = "Root" && $.userIdentity.invokedBy NOT EXISTS && $.eventType != "AwsServiceEvent" }`,
+			`{$.eventType!="AwsServiceEvent" && $.userIdentity.invokedBy NOT EXISTS && $.userIdentity.type="Root"}`},
+		{`{ $.userIdentity.sessionContext.attributes.mfaAuthenticated !="true" }`,
+			`{ $.userIdentity.sessionContext.attributes.mfaAuthenticated != "true" }`},
+	}
+	for _, tt := range tests {
+		original, candidate := mustParse(t, tt.original), mustParse(t, tt.candidate)
+		if missing := filterpattern.Missing(original, candidate); len(missing) > 0 {
+			t.Errorf("%s doesn't cover %s, missing %q", tt.candidate, tt.original, missing)
+		}
+		if missing := filterpattern.Missing(candidate, original); len(missing) > 0 {
+			t.Errorf("%s doesn't cover %s, missing %q", tt.original, tt.candidate, missing)
+		}
+		if original.String() != candidate.String() {
+			t.Errorf("%s and %s normalize to %q and %q", tt.original, tt.candidate, original.String(), candidate.String())
+		}
+	}
+}
+
+// A broader pattern covers a narrower one, but not the reverse
+func TestBroaderCovers(t *testing.T) {
+	tests := []struct {
+		name, narrow, broad string
+		missing             []string // parts of the broad pattern the narrow one misses
+	}{
+		{"extra alternative",
+			`{ ($.eventName = CreateTrail) || ($.eventName = DeleteTrail) }`,
+			`{ ($.eventName = CreateTrail) || ($.eventName = DeleteTrail) || ($.eventName = StopLogging) }`,
+			[]string{"$.eventName = StopLogging"}},
+		{"fewer conditions",
+			`{ ($.eventSource = kms.amazonaws.com) && ($.eventName = DisableKey) }`,
+			`{ $.eventName = DisableKey }`,
+			[]string{"$.eventName = DisableKey"}},
+		{"wildcard suffix",
+			`{ $.errorCode = "*UnauthorizedOperation" }`,
+			`{ $.errorCode = "*Operation" }`,
+			[]string{"$.errorCode = *Operation"}},
+		{"wildcard spans slashes",
+			`{ $.eventSource = "arn:aws:iam::role/admin" }`,
+			`{ $.eventSource = * }`,
+			[]string{"$.eventSource = *"}},
+		{"wildcard in the middle",
+			`{ $.errorCode = "AccessDenied?[x]" }`,
+			`{ $.errorCode = "Access*[x]" }`,
+			[]string{"$.errorCode = Access*[x]"}},
+	}
+	for _, tt := range tests {
+		narrow, broad := mustParse(t, tt.narrow), mustParse(t, tt.broad)
+		if missing := filterpattern.Missing(narrow, broad); len(missing) > 0 {
+			t.Errorf("%s: %s doesn't cover %s, missing %q", tt.name, tt.broad, tt.narrow, missing)
+		}
+		if missing := filterpattern.Missing(broad, narrow); !reflect.DeepEqual(missing, tt.missing) {
+			t.Errorf("%s: Missing(%s, %s) = %q, want %q", tt.name, tt.broad, tt.narrow, missing, tt.missing)
+		}
+	}
+}
+
+// Wildcards only stand for "*", so "?" and "[...]" are plain characters
+func TestWildcardIsLiteral(t *testing.T) {
+	tests := []struct {
+		candidate, required string
+	}{
+		{`{ $.errorCode = "Access?" }`, `{ $.errorCode = "AccessX" }`},
+		{`{ $.errorCode = "*[ab]" }`, `{ $.errorCode = "Denieda" }`},
+		{`{ $.errorCode = "Access*Denied" }`, `{ $.errorCode = "AccessDenie" }`},
+		{`{ $.errorCode = "a*a" }`, `{ $.errorCode = "a" }`},
+	}
+	for _, tt := range tests {
+		if missing := filterpattern.Missing(mustParse(t, tt.required), mustParse(t, tt.candidate)); len(missing) == 0 {
+			t.Errorf("%s covers %s, want it missing", tt.candidate, tt.required)
+		}
+	}
+}
+
+func TestMissingReportsTerms(t *testing.T) {
+	required := mustParse(t, benchmark.FilterPatterns[6]) // 3.7
+	tests := []struct {
+		candidate string
+		missing   []string
+	}{
+		{`{ ($.eventSource = kms.amazonaws.com) && ($.eventName = DisableKey) }`,
+			[]string{"$.eventName = ScheduleKeyDeletion && $.eventSource = kms.amazonaws.com"}},
+		{`{ $.eventName = CreateKey }`,
+			[]string{"$.eventName = DisableKey && $.eventSource = kms.amazonaws.com", "$.eventName = ScheduleKeyDeletion && $.eventSource = kms.amazonaws.com"}},
+		{`{ ($.eventName = DisableKey) || ($.eventName = ScheduleKeyDeletion) }`, nil},
+	}
+	for _, tt := range tests {
+		if missing := filterpattern.Missing(required, mustParse(t, tt.candidate)); !reflect.DeepEqual(missing, tt.missing) {
+			t.Errorf("Missing(3.7, %s) = %q, want %q", tt.candidate, missing, tt.missing)
+		}
+	}
+}
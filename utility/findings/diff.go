@@ -0,0 +1,100 @@
+package findings
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Change describes how a single check differs between two scans
+type Change struct {
+	Name        string
+	Description string
+	Before      string
+	After       string
+	Opened      []Resource
+	Resolved    []Resource
+}
+
+/*
+Diff compares two scans and returns the checks whose status changed, or that have resources
+that were opened or resolved between them, in benchmark order
+*/
+func Diff(before, after Scan) []Change {
+	names := make(map[string]bool)
+	for name := range before.Checks {
+		names[name] = true
+	}
+	for name := range after.Checks {
+		names[name] = true
+	}
+
+	var changes []Change
+	for name := range names {
+		b, a := before.Checks[name], after.Checks[name]
+		c := Change{Name: name, Description: a.Description, Before: b.Status.Open, After: a.Status.Open}
+		if c.Description == "" {
+			c.Description = b.Description
+		}
+		wasOpen := openResources(b)
+		isOpen := openResources(a)
+		for key, r := range isOpen {
+			if _, ok := wasOpen[key]; !ok {
+				c.Opened = append(c.Opened, r)
+			}
+		}
+		for key, r := range wasOpen {
+			if _, ok := isOpen[key]; !ok {
+				c.Resolved = append(c.Resolved, r)
+			}
+		}
+		sortResources(c.Opened)
+		sortResources(c.Resolved)
+		if c.Before != c.After || len(c.Opened) > 0 || len(c.Resolved) > 0 {
+			changes = append(changes, c)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return LessID(changes[i].Name, changes[j].Name) })
+	return changes
+}
+
+func openResources(f Finding) map[string]Resource {
+	open := make(map[string]Resource)
+	for _, r := range f.Resources {
+		if r.Open == FindingOpen {
+			open[r.Region+"/"+r.ID] = r
+		}
+	}
+	return open
+}
+
+func sortResources(r []Resource) {
+	sort.Slice(r, func(i, j int) bool {
+		if r[i].Region != r[j].Region {
+			return r[i].Region < r[j].Region
+		}
+		return r[i].ID < r[j].ID
+	})
+}
+
+/*
+LessID orders check IDs or finding names the way the benchmark does, so "1.2" comes before "1.10"
+*/
+func LessID(a, b string) bool {
+	pa := strings.Split(strings.TrimPrefix(a, "Finding "), ".")
+	pb := strings.Split(strings.TrimPrefix(b, "Finding "), ".")
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		na, errA := strconv.Atoi(pa[i])
+		nb, errB := strconv.Atoi(pb[i])
+		if errA != nil || errB != nil {
+			if pa[i] != pb[i] {
+				return pa[i] < pb[i]
+			}
+			continue
+		}
+		if na != nb {
+			return na < nb
+		}
+	}
+	return len(pa) < len(pb)
+}
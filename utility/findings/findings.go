@@ -1,25 +1,167 @@
 package findings
 
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
 // Status holds the state of a given finding - if it's been checked, and is it Open/Other
 type Status struct {
 	Checked bool
 	Open    string
 }
 
+// Resource holds the result of a check against a single resource (a user, a trail, a security group...).
+// Name is set when the resource has a friendlier name than its ID, eg: a security group name.
+type Resource struct {
+	Region      string
+	ID          string
+	Name        string `json:",omitempty"`
+	Open        string
+	Evidence    string
+	Fingerprint string
+}
+
+// Remediation holds the guidance for fixing a failing check: what to change, the AWS CLI
+// commands that change it (with the failing resources filled in where they are known) and
+// the same steps in the console
+type Remediation struct {
+	Explanation string
+	CLI         []string
+	Console     []string
+}
+
 // Finding holds a finding plus it's state at a given moment
 type Finding struct {
 	Name        string
 	Description string
 	Status      Status
 	Notes       map[string]string
+	Resources   []Resource
+	Fingerprint string
+	Remediation *Remediation `json:",omitempty"`
+}
+
+/*
+AddResource records the result of a check against a single resource.  Global resources
+(IAM users, the root account) have an empty region.
+*/
+func (f *Finding) AddResource(region, id, open, evidence string) {
+	f.Resources = append(f.Resources, Resource{Region: region, ID: id, Open: open, Evidence: evidence})
+}
+
+// YesNo writes a flag in evidence and listings as "yes" or "no"
+func YesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// ID returns the benchmark identifier for a finding, eg: "2.7" for "Finding 2.7"
+func (f Finding) ID() string {
+	return strings.TrimPrefix(f.Name, "Finding ")
+}
+
+// Section returns the benchmark section a finding belongs to, eg: "2" for "Finding 2.7"
+func (f Finding) Section() string {
+	return strings.SplitN(f.ID(), ".", 2)[0]
+}
+
+/*
+OpenResources returns the resources the check failed against
+*/
+func (f Finding) OpenResources() []Resource {
+	var open []Resource
+	for i := range f.Resources {
+		if f.Resources[i].Open == FindingOpen {
+			open = append(open, f.Resources[i])
+		}
+	}
+	return open
+}
+
+/*
+Merge combines the results of a check run in another region with an existing finding.
+The finding is open if it is open in any region, and the resources and notes of both are kept.
+*/
+func Merge(prev, next Finding) Finding {
+	if !prev.Status.Checked {
+		return next
+	}
+	merged := next
+	merged.Status.Open = worstStatus(prev.Status.Open, next.Status.Open)
+	merged.Resources = append(append([]Resource{}, prev.Resources...), next.Resources...)
+	merged.Notes = make(map[string]string)
+	for k, v := range prev.Notes {
+		merged.Notes[k] = v
+	}
+	for k, v := range next.Notes {
+		if merged.Notes[k] != "" && v != "" {
+			merged.Notes[k] = merged.Notes[k] + ", " + v
+		} else if v != "" {
+			merged.Notes[k] = v
+		}
+	}
+	return merged
+}
+
+func worstStatus(a, b string) string {
+	if a == FindingOpen || b == FindingOpen {
+		return FindingOpen
+	}
+	if a == FindingUnk || b == FindingUnk {
+		return FindingUnk
+	}
+	if a == FindingAccepted || b == FindingAccepted {
+		return FindingAccepted
+	}
+	return FindingClosed
+}
+
+/*
+Fingerprint returns a stable identifier for the result of a check against a resource in an
+account, so the same result can be correlated across scans.  Account level results use an
+empty region and resource.
+*/
+func Fingerprint(checkID, account, region, resource string) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{checkID, account, region, resource}, "|")))
+	return hex.EncodeToString(sum[:])
 }
 
 // Checks is a mapping of Findings to slugs
 type Checks map[string]Finding
 
+/*
+Stamp sets the fingerprint on every finding and resource result for the given account
+*/
+func (c Checks) Stamp(account string) {
+	for name, f := range c {
+		f.Fingerprint = Fingerprint(f.ID(), account, "", "")
+		for i := range f.Resources {
+			f.Resources[i].Fingerprint = Fingerprint(f.ID(), account, f.Resources[i].Region, f.Resources[i].ID)
+		}
+		c[name] = f
+	}
+}
+
+// Scan holds the results of a single run of the scanner against an account
+type Scan struct {
+	Account          string
+	Timestamp        time.Time
+	BenchmarkVersion string
+	Regions          []string
+	Checks           Checks
+}
+
 // FindingsInCISBenchmark is the number of total findings in the benchmark
 const FindingsInCISBenchmark = 43
 
+// BenchmarkVersion is the version of the CIS Benchmark the checks are written against
+const BenchmarkVersion = "1.0.0"
+
 // FindingOpen indicates a check is 'open' or Failed
 const FindingOpen = "Open"
 
@@ -28,3 +170,9 @@ const FindingClosed = "Closed"
 
 // FindingUnk indicates a check is in an 'unknown' or untestable state
 const FindingUnk = "Unknown"
+
+// FindingNA indicates a resource the check doesn't apply to, eg: a KMS key that can't be rotated
+const FindingNA = "Not Applicable"
+
+// FindingAccepted indicates a check failed, but the risk has been accepted with a waiver
+const FindingAccepted = "Accepted Risk"
@@ -0,0 +1,321 @@
+package history
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/adamcrosby/aws-cis-scanner/utility/findings"
+)
+
+// DirName is the directory (under the users home directory) the scanner keeps its state in
+const DirName = ".aws-cis-scanner"
+
+// FileName is the name of the single file history store
+const FileName = "history.json"
+
+// Store holds every scan that has been recorded, and where it is saved on disk
+type Store struct {
+	path       string
+	Scans      []findings.Scan
+	Lifecycles map[string]*Lifecycle
+}
+
+// Lifecycle tracks a single open result, identified by its fingerprint, across scans
+type Lifecycle struct {
+	Fingerprint string
+	Check       string
+	Account     string
+	Region      string
+	Resource    string
+	FirstSeen   time.Time
+	LastSeen    time.Time
+	ResolvedAt  *time.Time `json:",omitempty"`
+}
+
+// SectionTrend holds the pass rate (in percent) of each benchmark section for a single scan
+type SectionTrend struct {
+	Timestamp time.Time
+	PassRate  map[string]float64
+}
+
+/*
+Has reports whether a section was run in the scan.  Sections left out by the check selection have
+no pass rate, rather than 0%.
+*/
+func (t SectionTrend) Has(section string) bool {
+	_, ok := t.PassRate[section]
+	return ok
+}
+
+// OpenFinding is a finding that is open in the latest scan, along with when it was first seen open
+type OpenFinding struct {
+	Name        string
+	Description string
+	OpenSince   time.Time
+	Days        int
+}
+
+// Trend holds the history of a single account for reporting
+type Trend struct {
+	Account  string
+	Sections []SectionTrend
+	Open     []OpenFinding
+}
+
+/*
+DefaultPath returns the location of the history store in the users home directory
+*/
+func DefaultPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = "."
+	}
+	return filepath.Join(home, DirName, FileName)
+}
+
+/*
+Open loads the history store at path, returning an empty store if it doesn't exist yet
+*/
+func Open(path string) (*Store, error) {
+	s := &Store{path: path}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, err
+	}
+	sort.Slice(s.Scans, func(i, j int) bool { return s.Scans[i].Timestamp.Before(s.Scans[j].Timestamp) })
+	return s, nil
+}
+
+/*
+Add records a scan in the store and updates the lifecycle of every result in it.  Call Save
+to write it to disk.
+*/
+func (s *Store) Add(scan findings.Scan) {
+	s.Scans = append(s.Scans, scan)
+	sort.Slice(s.Scans, func(i, j int) bool { return s.Scans[i].Timestamp.Before(s.Scans[j].Timestamp) })
+	s.track(scan)
+}
+
+/*
+track records first and last seen times for every open result in a scan, and marks results
+that are no longer open as resolved.  A result is only resolved if its check was run in the
+results region this time, so a scan of a single region or section doesn't resolve everything else.
+*/
+func (s *Store) track(scan findings.Scan) {
+	if s.Lifecycles == nil {
+		s.Lifecycles = make(map[string]*Lifecycle)
+	}
+	seen := make(map[string]bool)
+	for _, f := range scan.Checks {
+		if !f.Status.Checked {
+			continue
+		}
+		// Accepted risks are still present, so they're tracked like open results rather than resolved
+		open := 0
+		for _, r := range f.Resources {
+			if r.Open == findings.FindingOpen || r.Open == findings.FindingAccepted {
+				s.seen(scan, f.ID(), r.Region, r.ID)
+				seen[findings.Fingerprint(f.ID(), scan.Account, r.Region, r.ID)] = true
+				open++
+			}
+		}
+		// Account level results are only tracked when there's no resource to pin them on
+		if open == 0 && (f.Status.Open == findings.FindingOpen || f.Status.Open == findings.FindingAccepted) {
+			s.seen(scan, f.ID(), "", "")
+			seen[findings.Fingerprint(f.ID(), scan.Account, "", "")] = true
+		}
+	}
+
+	scanned := make(map[string]bool)
+	for _, r := range scan.Regions {
+		scanned[r] = true
+	}
+	for fp, l := range s.Lifecycles {
+		if l.Account != scan.Account || l.ResolvedAt != nil || seen[fp] {
+			continue
+		}
+		if f, ok := scan.Checks["Finding "+l.Check]; !ok || !f.Status.Checked {
+			continue
+		}
+		if l.Region != "" && !scanned[l.Region] {
+			continue
+		}
+		resolved := scan.Timestamp
+		l.ResolvedAt = &resolved
+	}
+}
+
+func (s *Store) seen(scan findings.Scan, check, region, resource string) {
+	fp := findings.Fingerprint(check, scan.Account, region, resource)
+	l, ok := s.Lifecycles[fp]
+	if !ok {
+		l = &Lifecycle{
+			Fingerprint: fp,
+			Check:       check,
+			Account:     scan.Account,
+			Region:      region,
+			Resource:    resource,
+			FirstSeen:   scan.Timestamp}
+		s.Lifecycles[fp] = l
+	}
+	// A result that comes back after being resolved is reopened, keeping its original first seen time
+	l.LastSeen = scan.Timestamp
+	l.ResolvedAt = nil
+}
+
+/*
+LifecyclesForAccount returns the lifecycle of every result recorded for an account, ordered by check and resource
+*/
+func (s *Store) LifecyclesForAccount(account string) []Lifecycle {
+	var list []Lifecycle
+	for _, l := range s.Lifecycles {
+		if l.Account == account {
+			list = append(list, *l)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Check != list[j].Check {
+			return list[i].Check < list[j].Check
+		}
+		if list[i].Region != list[j].Region {
+			return list[i].Region < list[j].Region
+		}
+		return list[i].Resource < list[j].Resource
+	})
+	return list
+}
+
+/*
+Save writes the store to disk, creating the state directory if needed.  The file is
+written to a temporary name and renamed so an interrupted scan can't corrupt it.
+*/
+func (s *Store) Save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+/*
+ForAccount returns every scan recorded for an account, oldest first
+*/
+func (s *Store) ForAccount(account string) []findings.Scan {
+	var scans []findings.Scan
+	for i := range s.Scans {
+		if s.Scans[i].Account == account {
+			scans = append(scans, s.Scans[i])
+		}
+	}
+	return scans
+}
+
+/*
+Accounts returns the list of accounts that have scans recorded
+*/
+func (s *Store) Accounts() []string {
+	seen := make(map[string]bool)
+	var accounts []string
+	for i := range s.Scans {
+		if !seen[s.Scans[i].Account] {
+			seen[s.Scans[i].Account] = true
+			accounts = append(accounts, s.Scans[i].Account)
+		}
+	}
+	sort.Strings(accounts)
+	return accounts
+}
+
+/*
+Trend calculates the pass rate per section for every scan of an account up to now, and how
+long each finding open in the most recent of those scans has been open for
+*/
+func (s *Store) Trend(account string, now time.Time) Trend {
+	t := Trend{Account: account}
+	var scans []findings.Scan
+	for _, scan := range s.ForAccount(account) {
+		if !scan.Timestamp.After(now) {
+			scans = append(scans, scan)
+		}
+	}
+	for i := range scans {
+		t.Sections = append(t.Sections, SectionTrend{Timestamp: scans[i].Timestamp, PassRate: PassRates(scans[i].Checks)})
+	}
+	if len(scans) == 0 {
+		return t
+	}
+
+	latest := scans[len(scans)-1]
+	for name, f := range latest.Checks {
+		if f.Status.Open != findings.FindingOpen {
+			continue
+		}
+		since := latest.Timestamp
+		// Walk backwards until the finding was last seen in any other state.  Scans
+		// where it wasn't run at all (eg: a region or check was skipped) don't break the streak.
+		for i := len(scans) - 2; i >= 0; i-- {
+			prev, ok := scans[i].Checks[name]
+			if !ok || !prev.Status.Checked {
+				continue
+			}
+			if prev.Status.Open != findings.FindingOpen {
+				break
+			}
+			since = scans[i].Timestamp
+		}
+		t.Open = append(t.Open, OpenFinding{
+			Name:        name,
+			Description: f.Description,
+			OpenSince:   since,
+			Days:        int(now.Sub(since).Hours() / 24)})
+	}
+	sort.Slice(t.Open, func(i, j int) bool {
+		if t.Open[i].OpenSince.Equal(t.Open[j].OpenSince) {
+			return t.Open[i].Name < t.Open[j].Name
+		}
+		return t.Open[i].OpenSince.Before(t.Open[j].OpenSince)
+	})
+	return t
+}
+
+/*
+PassRates returns the percentage of checked findings that are closed in each section
+*/
+func PassRates(checks findings.Checks) map[string]float64 {
+	passed := make(map[string]int)
+	total := make(map[string]int)
+	for _, f := range checks {
+		if !f.Status.Checked {
+			continue
+		}
+		switch f.Status.Open {
+		case findings.FindingClosed:
+			passed[f.Section()]++
+			total[f.Section()]++
+		case findings.FindingOpen:
+			total[f.Section()]++
+		}
+	}
+	rates := make(map[string]float64)
+	for section := range total {
+		rates[section] = float64(int(float64(passed[section])/float64(total[section])*1000)) / 10
+	}
+	return rates
+}
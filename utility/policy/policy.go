@@ -0,0 +1,527 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Who a statement is open to
+const (
+	Anyone        = "anyone"
+	Authenticated = "any authenticated AWS user"
+)
+
+// Value is a policy element that may be written as a single string or a list of them, eg: Action
+type Value []string
+
+// UnmarshalJSON accepts a string, a list of strings, or (in conditions) booleans and numbers
+func (v *Value) UnmarshalJSON(b []byte) error {
+	var raw interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	*v = nil
+	items, ok := raw.([]interface{})
+	if !ok {
+		items = []interface{}{raw}
+	}
+	for _, item := range items {
+		switch t := item.(type) {
+		case string:
+			*v = append(*v, t)
+		case bool, float64:
+			*v = append(*v, fmt.Sprint(t))
+		default:
+			return fmt.Errorf("unexpected value %s", string(b))
+		}
+	}
+	return nil
+}
+
+// Principal is who a statement applies to: everyone ("*"), or lists of principals by type (AWS,
+// Service, Federated or CanonicalUser)
+type Principal struct {
+	All    bool
+	Values map[string]Value
+}
+
+// UnmarshalJSON accepts "*" or an object of principal types
+func (p *Principal) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		if s != "*" {
+			return fmt.Errorf("unexpected principal %q", s)
+		}
+		p.All = true
+		return nil
+	}
+	return json.Unmarshal(b, &p.Values)
+}
+
+// Wildcard reports whether the principal includes every AWS principal, ie: "*" or {"AWS": "*"}
+func (p *Principal) Wildcard() bool {
+	if p == nil {
+		return false
+	}
+	if p.All {
+		return true
+	}
+	for _, v := range p.Values["AWS"] {
+		if v == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Statement is a single statement of a policy document
+type Statement struct {
+	Sid          string
+	Effect       string
+	Principal    *Principal
+	NotPrincipal *Principal
+	Action       Value
+	NotAction    Value
+	Resource     Value
+	NotResource  Value
+	Condition    map[string]map[string]Value
+}
+
+// Document is an IAM policy document, eg: an S3 bucket policy or a KMS key policy
+type Document struct {
+	Version   string
+	Statement []Statement
+}
+
+/*
+Parse decodes a policy document.  Statement may be a single statement or a list of them.
+*/
+func Parse(doc string) (*Document, error) {
+	var raw struct {
+		Version   string
+		Statement json.RawMessage
+	}
+	if err := json.Unmarshal([]byte(doc), &raw); err != nil {
+		return nil, err
+	}
+	d := &Document{Version: raw.Version}
+	if len(raw.Statement) == 0 {
+		return d, nil
+	}
+	if strings.HasPrefix(strings.TrimSpace(string(raw.Statement)), "{") {
+		var s Statement
+		if err := json.Unmarshal(raw.Statement, &s); err != nil {
+			return nil, err
+		}
+		d.Statement = []Statement{s}
+		return d, nil
+	}
+	if err := json.Unmarshal(raw.Statement, &d.Statement); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Exposure is an Allow statement that is open to anyone, or to any authenticated AWS user
+type Exposure struct {
+	Sid     string
+	Who     string
+	Actions []string
+}
+
+// String describes the exposure, eg: `statement "Public" allows s3:GetObject to anyone`
+func (e Exposure) String() string {
+	name := "statement"
+	if e.Sid != "" {
+		name = fmt.Sprintf("statement %q", e.Sid)
+	}
+	return fmt.Sprintf("%s allows %s to %s", name, strings.Join(e.Actions, ", "), e.Who)
+}
+
+/*
+Exposures returns the Allow statements of a document that are open to anyone or to any
+authenticated AWS user.  A statement is open when its principal is "*" (or {"AWS": "*"}), an
+account wildcard such as "arn:aws:iam::*:root", or a NotPrincipal, unless:
+
+  - a condition pins it to fixed values of a key identifying the caller or the source of the
+    request, eg: aws:PrincipalOrgID, aws:SourceArn, aws:SourceVpce or a non-zero aws:SourceIp
+    range;
+  - a Deny statement for every principal covering its actions and resources either has no
+    condition, or only negated conditions on such keys (eg: StringNotEquals aws:PrincipalOrgID).
+
+A "*" principal with a condition on the caller's identity (eg: aws:PrincipalArn) excludes
+anonymous requests, so it is reported as open to any authenticated AWS user.
+*/
+func (d *Document) Exposures() []Exposure {
+	var out []Exposure
+	for _, s := range d.Statement {
+		if !strings.EqualFold(s.Effect, "Allow") {
+			continue
+		}
+		who := s.openTo()
+		if who == "" || s.restricted() {
+			continue
+		}
+		if who == Anyone && s.requiresIdentity() {
+			who = Authenticated
+		}
+		if d.denied(s) {
+			continue
+		}
+		out = append(out, Exposure{Sid: s.Sid, Who: who, Actions: s.actions()})
+	}
+	return out
+}
+
+/*
+CrossAccount returns the Allow statements of a document that name AWS principals in accounts other
+than the given one, with Who set to the other account, eg: "account 444455556666".  A statement open
+to anyone counts when a condition pins the caller's account (aws:PrincipalAccount or
+kms:CallerAccount); otherwise it is left to Exposures.  A statement naming several other accounts
+is returned once for each of them.
+*/
+func (d *Document) CrossAccount(account string) []Exposure {
+	var out []Exposure
+	for _, s := range d.Statement {
+		if !strings.EqualFold(s.Effect, "Allow") || s.Principal == nil || d.denied(s) {
+			continue
+		}
+		principals := s.Principal.Values["AWS"]
+		if s.openTo() != "" {
+			principals = s.conditionAccounts()
+		}
+		seen := make(map[string]bool)
+		for _, p := range principals {
+			other := AccountOf(p)
+			if other == "" || other == account || seen[other] {
+				continue
+			}
+			seen[other] = true
+			out = append(out, Exposure{Sid: s.Sid, Who: "account " + other, Actions: s.actions()})
+		}
+	}
+	return out
+}
+
+// conditionAccounts returns the accounts a statement open to anyone is pinned to by a condition on
+// the caller's account, eg: StringEquals kms:CallerAccount
+func (s Statement) conditionAccounts() []string {
+	var accounts []string
+	for op, keys := range s.Condition {
+		if !restrictingOperators[baseOperator(op)] {
+			continue
+		}
+		for key, values := range keys {
+			switch strings.ToLower(key) {
+			case "aws:principalaccount", "kms:calleraccount":
+				if restrictingKey(key, values) {
+					accounts = append(accounts, values...)
+				}
+			}
+		}
+	}
+	return accounts
+}
+
+/*
+AllowsAccount reports whether an Allow statement names the account itself (its ID or root user ARN)
+as a principal for the action, and returns the statement's Sid.  Conditions are not evaluated.
+*/
+func (d *Document) AllowsAccount(account, action string) (string, bool) {
+	for _, s := range d.Statement {
+		if !strings.EqualFold(s.Effect, "Allow") || s.Principal == nil || s.NotPrincipal != nil {
+			continue
+		}
+		if len(s.NotAction) > 0 || !covers(s.Action, Value{action}, nil, true) {
+			continue
+		}
+		for _, p := range s.Principal.Values["AWS"] {
+			if p == account || (strings.HasPrefix(p, "arn:") && strings.HasSuffix(p, ":iam::"+account+":root")) {
+				return s.Sid, true
+			}
+		}
+	}
+	return "", false
+}
+
+/*
+DeniesInsecureTransport returns the Sid of a Deny statement for every principal that refuses
+requests made without TLS (aws:SecureTransport false) for every action, or for the given actions,
+and whether there is one
+*/
+func (d *Document) DeniesInsecureTransport(actions ...string) (string, bool) {
+	for _, s := range d.Statement {
+		if !strings.EqualFold(s.Effect, "Deny") || !s.Principal.Wildcard() || len(s.NotAction) > 0 {
+			continue
+		}
+		if !covers(s.Action, actions, nil, true) {
+			continue
+		}
+		for op, keys := range s.Condition {
+			if b := baseOperator(op); b != "Bool" && b != "BoolIfExists" {
+				continue
+			}
+			for key, values := range keys {
+				if strings.EqualFold(key, "aws:SecureTransport") && len(values) == 1 && strings.EqualFold(values[0], "false") {
+					return s.Sid, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+/*
+AccountOf returns the account ID of an AWS principal, given as an account ID or an ARN such as
+"arn:aws:iam::444455556666:role/reader", or "" if it doesn't name one
+*/
+func AccountOf(principal string) string {
+	if accountID.MatchString(principal) {
+		return principal
+	}
+	if parts := strings.Split(principal, ":"); len(parts) >= 6 && parts[0] == "arn" && accountID.MatchString(parts[4]) {
+		return parts[4]
+	}
+	return ""
+}
+
+var accountID = regexp.MustCompile(`^[0-9]{12}$`)
+
+// actions returns the statement's actions for display, sorted
+func (s Statement) actions() []string {
+	if len(s.NotAction) > 0 {
+		return []string{"everything except " + strings.Join(s.NotAction, ", ")}
+	}
+	actions := append([]string{}, s.Action...)
+	sort.Strings(actions)
+	return actions
+}
+
+// openTo returns who a statement's principal covers, or "" for specific principals
+func (s Statement) openTo() string {
+	if s.NotPrincipal != nil || s.Principal.Wildcard() {
+		return Anyone
+	}
+	if s.Principal == nil {
+		return ""
+	}
+	for _, v := range s.Principal.Values["AWS"] {
+		if strings.ContainsAny(v, "*?") {
+			return Authenticated
+		}
+	}
+	return ""
+}
+
+// restricted reports whether one of the statement's conditions pins it to fixed values of a
+// restricting key
+func (s Statement) restricted() bool {
+	for op, keys := range s.Condition {
+		if !restrictingOperators[baseOperator(op)] {
+			continue
+		}
+		for key, values := range keys {
+			if restrictingKey(key, values) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// requiresIdentity reports whether a condition needs a key only authenticated requests have
+func (s Statement) requiresIdentity() bool {
+	for op, keys := range s.Condition {
+		base := baseOperator(op)
+		if base == "Null" || strings.HasSuffix(base, "IfExists") {
+			continue
+		}
+		for key := range keys {
+			if identityKeys[strings.ToLower(key)] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/*
+denied reports whether a Deny statement for every principal takes away the access an Allow gives:
+it covers all of its actions and resources, and is either unconditional or only lets through
+requests with fixed values of a restricting key
+*/
+func (d *Document) denied(allow Statement) bool {
+	for _, s := range d.Statement {
+		if !strings.EqualFold(s.Effect, "Deny") || !s.Principal.Wildcard() || len(s.NotAction) > 0 || len(s.NotResource) > 0 {
+			continue
+		}
+		if !covers(s.Action, allow.Action, allow.NotAction, true) || !covers(s.Resource, allow.Resource, allow.NotResource, false) {
+			continue
+		}
+		if len(s.Condition) == 0 || s.onlyNegatedRestrictions() {
+			return true
+		}
+	}
+	return false
+}
+
+// onlyNegatedRestrictions reports whether every condition of a Deny is a negated restricting one
+func (s Statement) onlyNegatedRestrictions() bool {
+	for op, keys := range s.Condition {
+		base := baseOperator(op)
+		if !negatedOperators[base] {
+			return false
+		}
+		for key, values := range keys {
+			if !restrictingKey(key, values) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// covers reports whether the patterns match everything an Allow's Action (or Resource) list does.
+// An Allow using NotAction (or NotResource) is only covered by "*".
+func covers(patterns, allow, notAllow Value, fold bool) bool {
+	if len(notAllow) > 0 || len(allow) == 0 {
+		for _, p := range patterns {
+			if p == "*" {
+				return true
+			}
+		}
+		return false
+	}
+	for _, a := range allow {
+		matched := false
+		for _, p := range patterns {
+			if Match(p, a, fold) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+Match reports whether a policy pattern (with * and ? wildcards) matches a value.  A pattern also
+matches a value containing wildcards when it matches the value's literal text, so "s3:*" covers
+"s3:Get*".  Actions are matched without regard to case.
+*/
+func Match(pattern, value string, fold bool) bool {
+	expr := strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(regexp.QuoteMeta(pattern))
+	if fold {
+		expr = "(?i)" + expr
+	}
+	ok, _ := regexp.MatchString("^"+expr+"$", value)
+	return ok
+}
+
+// baseOperator strips the set qualifiers from a condition operator, eg: ForAnyValue:StringEquals
+func baseOperator(op string) string {
+	if i := strings.Index(op, ":"); i >= 0 {
+		return op[i+1:]
+	}
+	return op
+}
+
+// restrictingOperators only match requests with the given values
+var restrictingOperators = map[string]bool{
+	"StringEquals":           true,
+	"StringEqualsIgnoreCase": true,
+	"StringLike":             true,
+	"ArnEquals":              true,
+	"ArnLike":                true,
+	"IpAddress":              true,
+}
+
+// negatedOperators match every request without the given values
+var negatedOperators = map[string]bool{
+	"StringNotEquals":           true,
+	"StringNotEqualsIgnoreCase": true,
+	"StringNotLike":             true,
+	"ArnNotEquals":              true,
+	"ArnNotLike":                true,
+	"NotIpAddress":              true,
+}
+
+// restrictingKeys identify who is calling or where the request comes from
+var restrictingKeys = map[string]bool{
+	"aws:principalorgid":        true,
+	"aws:principalorgpaths":     true,
+	"aws:principalaccount":      true,
+	"aws:principalarn":          true,
+	"aws:userid":                true,
+	"aws:sourcearn":             true,
+	"aws:sourceaccount":         true,
+	"aws:sourceowner":           true,
+	"aws:sourceorgid":           true,
+	"aws:sourceorgpaths":        true,
+	"aws:sourcevpc":             true,
+	"aws:sourcevpce":            true,
+	"aws:sourceip":              true,
+	"s3:dataaccesspointarn":     true,
+	"s3:dataaccesspointaccount": true,
+	"kms:calleraccount":         true,
+}
+
+// identityKeys are only present on requests signed by an AWS principal
+var identityKeys = map[string]bool{
+	"aws:principalarn":      true,
+	"aws:principalaccount":  true,
+	"aws:principalorgid":    true,
+	"aws:principalorgpaths": true,
+	"aws:principaltype":     true,
+	"aws:userid":            true,
+	"aws:username":          true,
+	"kms:calleraccount":     true,
+}
+
+/*
+restrictingKey reports whether a condition key and its values limit a statement to known callers
+or sources.  Values must be fixed: wildcards are only allowed after a fixed prefix for
+organization paths ("o-abc/r-def/*") and user IDs ("AROAEXAMPLE:*"), and IP ranges must not
+cover every address.
+*/
+func restrictingKey(key string, values Value) bool {
+	key = strings.ToLower(key)
+	if !restrictingKeys[key] || len(values) == 0 {
+		return false
+	}
+	for _, v := range values {
+		switch key {
+		case "aws:sourceip":
+			_, block, err := net.ParseCIDR(v)
+			if err != nil {
+				if net.ParseIP(v) == nil {
+					return false
+				}
+				continue
+			}
+			if ones, _ := block.Mask.Size(); ones == 0 {
+				return false
+			}
+		case "aws:principalorgpaths", "aws:sourceorgpaths":
+			if strings.ContainsAny(strings.TrimSuffix(v, "*"), "*?") || strings.HasPrefix(v, "*") {
+				return false
+			}
+		case "aws:userid":
+			if strings.ContainsAny(strings.TrimSuffix(v, ":*"), "*?") || strings.HasPrefix(v, "*") {
+				return false
+			}
+		default:
+			if strings.ContainsAny(v, "*?") {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,223 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+)
+
+const account = "111122223333"
+
+func mustParse(t *testing.T, doc string) *Document {
+	t.Helper()
+	d, err := Parse(doc)
+	if err != nil {
+		t.Fatalf("Parse(%s): %s", doc, err)
+	}
+	return d
+}
+
+func TestExposures(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want []Exposure
+	}{
+		{"principal star",
+			`{"Statement":{"Sid":"Public","Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"*"}}`,
+			[]Exposure{{Sid: "Public", Who: Anyone, Actions: []string{"s3:GetObject"}}}},
+		{"AWS star",
+			`{"Statement":[{"Effect":"Allow","Principal":{"AWS":"*"},"Action":["s3:PutObject","s3:GetObject"],"Resource":"*"}]}`,
+			[]Exposure{{Who: Anyone, Actions: []string{"s3:GetObject", "s3:PutObject"}}}},
+		{"AWS star in a list",
+			`{"Statement":[{"Effect":"Allow","Principal":{"AWS":["arn:aws:iam::111122223333:root","*"]},"Action":"s3:GetObject","Resource":"*"}]}`,
+			[]Exposure{{Who: Anyone, Actions: []string{"s3:GetObject"}}}},
+		{"account wildcard",
+			`{"Statement":[{"Effect":"Allow","Principal":{"AWS":"arn:aws:iam::*:root"},"Action":"s3:GetObject","Resource":"*"}]}`,
+			[]Exposure{{Who: Authenticated, Actions: []string{"s3:GetObject"}}}},
+		{"specific principal",
+			`{"Statement":[{"Effect":"Allow","Principal":{"AWS":"arn:aws:iam::111122223333:role/reader"},"Action":"s3:GetObject","Resource":"*"}]}`,
+			nil},
+		{"service principal",
+			`{"Statement":[{"Effect":"Allow","Principal":{"Service":"cloudtrail.amazonaws.com"},"Action":"s3:PutObject","Resource":"*"}]}`,
+			nil},
+		{"deny with star principal",
+			`{"Statement":[{"Effect":"Deny","Principal":"*","Action":"s3:*","Resource":"*"}]}`,
+			nil},
+		{"NotPrincipal",
+			`{"Statement":[{"Sid":"AllBut","Effect":"Allow","NotPrincipal":{"AWS":"arn:aws:iam::111122223333:role/x"},"Action":"s3:GetObject","Resource":"*"}]}`,
+			[]Exposure{{Sid: "AllBut", Who: Anyone, Actions: []string{"s3:GetObject"}}}},
+		{"NotAction",
+			`{"Statement":[{"Effect":"Allow","Principal":"*","NotAction":"s3:DeleteObject","Resource":"*"}]}`,
+			[]Exposure{{Who: Anyone, Actions: []string{"everything except s3:DeleteObject"}}}},
+		{"restricted to an organization",
+			`{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"*","Condition":{"StringEquals":{"aws:PrincipalOrgID":"o-abc123"}}}]}`,
+			nil},
+		{"restricted to a VPC endpoint",
+			`{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"*","Condition":{"StringEquals":{"aws:SourceVpce":["vpce-1","vpce-2"]}}}]}`,
+			nil},
+		{"restricted to an address range",
+			`{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"*","Condition":{"IpAddress":{"aws:SourceIp":"192.0.2.0/24"}}}]}`,
+			nil},
+		{"address range covering everything",
+			`{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"*","Condition":{"IpAddress":{"aws:SourceIp":"0.0.0.0/0"}}}]}`,
+			[]Exposure{{Who: Anyone, Actions: []string{"s3:GetObject"}}}},
+		{"wildcard organization",
+			`{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"*","Condition":{"StringLike":{"aws:PrincipalOrgID":"o-*"}}}]}`,
+			[]Exposure{{Who: Authenticated, Actions: []string{"s3:GetObject"}}}},
+		{"IfExists doesn't restrict",
+			`{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"*","Condition":{"StringEqualsIfExists":{"aws:SourceVpce":"vpce-1"}}}]}`,
+			[]Exposure{{Who: Anyone, Actions: []string{"s3:GetObject"}}}},
+		{"IfExists identity key allows anonymous callers",
+			`{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"*","Condition":{"StringLikeIfExists":{"aws:PrincipalArn":"arn:aws:iam::*:role/*"}}}]}`,
+			[]Exposure{{Who: Anyone, Actions: []string{"s3:GetObject"}}}},
+		{"identity key excludes anonymous callers",
+			`{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"*","Condition":{"StringLike":{"aws:PrincipalArn":"arn:aws:iam::*:role/*"}}}]}`,
+			[]Exposure{{Who: Authenticated, Actions: []string{"s3:GetObject"}}}},
+		{"unrelated condition",
+			`{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"*","Condition":{"Bool":{"aws:SecureTransport":true}}}]}`,
+			[]Exposure{{Who: Anyone, Actions: []string{"s3:GetObject"}}}},
+		{"deny overrides allow",
+			`{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::b/*"},
+			{"Effect":"Deny","Principal":"*","Action":"s3:*","Resource":"arn:aws:s3:::b/*"}]}`,
+			nil},
+		{"deny outside the organization overrides allow",
+			`{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"*"},
+			{"Effect":"Deny","Principal":{"AWS":"*"},"Action":"*","Resource":"*","Condition":{"StringNotEquals":{"aws:PrincipalOrgID":"o-abc123"}}}]}`,
+			nil},
+		{"deny for fewer actions",
+			`{"Statement":[{"Effect":"Allow","Principal":"*","Action":["s3:GetObject","s3:PutObject"],"Resource":"*"},
+			{"Effect":"Deny","Principal":"*","Action":"s3:PutObject","Resource":"*"}]}`,
+			[]Exposure{{Who: Anyone, Actions: []string{"s3:GetObject", "s3:PutObject"}}}},
+		{"deny for fewer resources",
+			`{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::b/*"},
+			{"Effect":"Deny","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::b/private/*"}]}`,
+			[]Exposure{{Who: Anyone, Actions: []string{"s3:GetObject"}}}},
+		{"deny for one principal",
+			`{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"*"},
+			{"Effect":"Deny","Principal":{"AWS":"arn:aws:iam::111122223333:role/x"},"Action":"s3:*","Resource":"*"}]}`,
+			[]Exposure{{Who: Anyone, Actions: []string{"s3:GetObject"}}}},
+		{"deny with a condition that doesn't restrict",
+			`{"Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"*"},
+			{"Effect":"Deny","Principal":"*","Action":"s3:*","Resource":"*","Condition":{"Bool":{"aws:SecureTransport":"false"}}}]}`,
+			[]Exposure{{Who: Anyone, Actions: []string{"s3:GetObject"}}}},
+		{"deny doesn't cover NotAction",
+			`{"Statement":[{"Effect":"Allow","Principal":"*","NotAction":"s3:DeleteObject","Resource":"*"},
+			{"Effect":"Deny","Principal":"*","Action":"s3:*","Resource":"*"}]}`,
+			[]Exposure{{Who: Anyone, Actions: []string{"everything except s3:DeleteObject"}}}},
+	}
+	for _, tt := range tests {
+		if got := mustParse(t, tt.doc).Exposures(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Exposures() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCrossAccount(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want []string // Who of each exposure
+	}{
+		{"same account",
+			`{"Statement":[{"Effect":"Allow","Principal":{"AWS":["arn:aws:iam::111122223333:root","111122223333"]},"Action":"kms:*","Resource":"*"}]}`,
+			nil},
+		{"other accounts, each once",
+			`{"Statement":[{"Effect":"Allow","Principal":{"AWS":["arn:aws:iam::444455556666:role/a","arn:aws:iam::444455556666:role/b","999988887777"]},"Action":"kms:Decrypt","Resource":"*"}]}`,
+			[]string{"account 444455556666", "account 999988887777"}},
+		{"caller account condition",
+			`{"Statement":[{"Effect":"Allow","Principal":{"AWS":"*"},"Action":"kms:Decrypt","Resource":"*","Condition":{"StringEquals":{"kms:CallerAccount":"444455556666"}}}]}`,
+			[]string{"account 444455556666"}},
+		{"open to anyone is left to Exposures",
+			`{"Statement":[{"Effect":"Allow","Principal":"*","Action":"kms:Decrypt","Resource":"*"}]}`,
+			nil},
+		{"service principal",
+			`{"Statement":[{"Effect":"Allow","Principal":{"Service":"cloudtrail.amazonaws.com"},"Action":"kms:GenerateDataKey*","Resource":"*"}]}`,
+			nil},
+		{"denied",
+			`{"Statement":[{"Effect":"Allow","Principal":{"AWS":"444455556666"},"Action":"kms:Decrypt","Resource":"*"},
+			{"Effect":"Deny","Principal":"*","Action":"kms:*","Resource":"*"}]}`,
+			nil},
+		{"deny statement",
+			`{"Statement":[{"Effect":"Deny","Principal":{"AWS":"444455556666"},"Action":"kms:Decrypt","Resource":"*"}]}`,
+			nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, e := range mustParse(t, tt.doc).CrossAccount(account) {
+			got = append(got, e.Who)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: CrossAccount() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDeniesInsecureTransport(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		actions []string
+		sid     string
+		ok      bool
+	}{
+		{"every action",
+			`{"Statement":[{"Sid":"TLS","Effect":"Deny","Principal":"*","Action":"*","Resource":"*","Condition":{"Bool":{"aws:SecureTransport":"false"}}}]}`,
+			nil, "TLS", true},
+		{"service wildcard for the given actions",
+			`{"Statement":[{"Sid":"TLS","Effect":"Deny","Principal":"*","Action":"s3:*","Resource":"*","Condition":{"Bool":{"aws:SecureTransport":"false"}}}]}`,
+			[]string{"s3:GetObject", "s3:PutObject"}, "TLS", true},
+		{"service wildcard isn't every action",
+			`{"Statement":[{"Sid":"TLS","Effect":"Deny","Principal":"*","Action":"s3:*","Resource":"*","Condition":{"Bool":{"aws:SecureTransport":"false"}}}]}`,
+			nil, "", false},
+		{"boolean value and AWS star",
+			`{"Statement":[{"Sid":"TLS","Effect":"Deny","Principal":{"AWS":"*"},"Action":"*","Resource":"*","Condition":{"Bool":{"aws:SecureTransport":false}}}]}`,
+			nil, "TLS", true},
+		{"BoolIfExists",
+			`{"Statement":[{"Sid":"TLS","Effect":"Deny","Principal":"*","Action":"*","Resource":"*","Condition":{"BoolIfExists":{"aws:SecureTransport":"false"}}}]}`,
+			nil, "TLS", true},
+		{"given actions",
+			`{"Statement":[{"Sid":"TLS","Effect":"Deny","Principal":"*","Action":["s3:GetObject","s3:PutObject"],"Resource":"*","Condition":{"Bool":{"aws:SecureTransport":"false"}}}]}`,
+			[]string{"s3:GetObject", "s3:PutObject"}, "TLS", true},
+		{"only some actions",
+			`{"Statement":[{"Sid":"TLS","Effect":"Deny","Principal":"*","Action":"s3:GetObject","Resource":"*","Condition":{"Bool":{"aws:SecureTransport":"false"}}}]}`,
+			nil, "", false},
+		{"only some of the given actions",
+			`{"Statement":[{"Sid":"TLS","Effect":"Deny","Principal":"*","Action":"s3:GetObject","Resource":"*","Condition":{"Bool":{"aws:SecureTransport":"false"}}}]}`,
+			[]string{"s3:GetObject", "s3:PutObject"}, "", false},
+		{"one principal",
+			`{"Statement":[{"Sid":"TLS","Effect":"Deny","Principal":{"AWS":"arn:aws:iam::111122223333:root"},"Action":"s3:*","Resource":"*","Condition":{"Bool":{"aws:SecureTransport":"false"}}}]}`,
+			nil, "", false},
+		{"allow",
+			`{"Statement":[{"Sid":"TLS","Effect":"Allow","Principal":"*","Action":"s3:*","Resource":"*","Condition":{"Bool":{"aws:SecureTransport":"true"}}}]}`,
+			nil, "", false},
+		{"NotAction",
+			`{"Statement":[{"Sid":"TLS","Effect":"Deny","Principal":"*","NotAction":"s3:GetObject","Resource":"*","Condition":{"Bool":{"aws:SecureTransport":"false"}}}]}`,
+			nil, "", false},
+		{"no condition",
+			`{"Statement":[{"Sid":"All","Effect":"Deny","Principal":"*","Action":"s3:*","Resource":"*"}]}`,
+			nil, "", false},
+	}
+	for _, tt := range tests {
+		sid, ok := mustParse(t, tt.doc).DeniesInsecureTransport(tt.actions...)
+		if sid != tt.sid || ok != tt.ok {
+			t.Errorf("%s: DeniesInsecureTransport() = %q, %v, want %q, %v", tt.name, sid, ok, tt.sid, tt.ok)
+		}
+	}
+}
+
+func TestAccountOf(t *testing.T) {
+	tests := map[string]string{
+		"444455556666":                          "444455556666",
+		"arn:aws:iam::444455556666:role/reader": "444455556666",
+		"arn:aws:iam::444455556666:root":        "444455556666",
+		"arn:aws:sqs:us-east-1:444455556666:q":  "444455556666",
+		"arn:aws:iam::*:root":                   "",
+		"cloudtrail.amazonaws.com":              "",
+		"*":                                     "",
+	}
+	for principal, want := range tests {
+		if got := AccountOf(principal); got != want {
+			t.Errorf("AccountOf(%q) = %q, want %q", principal, got, want)
+		}
+	}
+}
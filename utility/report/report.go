@@ -5,8 +5,16 @@ import (
 	"html/template"
 
 	"github.com/adamcrosby/aws-cis-scanner/utility/findings"
+	"github.com/adamcrosby/aws-cis-scanner/utility/history"
 )
 
+// Data is what the report templates are rendered with: the current results plus the
+// history of the account they were collected from
+type Data struct {
+	Checks findings.Checks
+	Trend  history.Trend
+}
+
 // StatusReplacer does string replacement for mah template
 func StatusReplacer(args ...interface{}) template.HTML {
 	ok := false
@@ -24,6 +32,13 @@ func StatusReplacer(args ...interface{}) template.HTML {
 	if s == findings.FindingClosed {
 		return template.HTML("<h3 class=\"label label-success\">Finding Closed</h3>")
 	}
+	if s == findings.FindingAccepted {
+		return template.HTML("<h3 class=\"label label-info\">Accepted Risk</h3>")
+	}
+	if s == "" {
+		// The check wasn't selected to run
+		return template.HTML("<h3 class=\"label label-warning\">Not Checked</h3>")
+	}
 	return template.HTML(s)
 }
 
@@ -53,23 +68,53 @@ const ReportTemplateHTML = `<!DOCTYPE html>
 		return trueCount;
 	}
 
-	var section1 = Array({{ (index . "Finding 1.1").Status.Open }},{{ (index . "Finding 1.2").Status.Open }},{{(index . "Finding 1.3").Status.Open}},{{ (index . "Finding 1.4").Status.Open }},{{ (index . "Finding 1.5").Status.Open }},{{ (index . "Finding 1.6").Status.Open }},{{ (index . "Finding 1.7").Status.Open }},{{ (index . "Finding 1.8").Status.Open }},{{ (index . "Finding 1.9").Status.Open }},{{ (index . "Finding 1.10").Status.Open }},{{ (index . "Finding 1.11").Status.Open }},{{ (index . "Finding 1.12").Status.Open }},{{ (index . "Finding 1.13").Status.Open }},{{ (index . "Finding 1.14").Status.Open }},{{ (index . "Finding 1.15").Status.Open }})
+	// Checks that weren't run have no status at all
+	function GetUncheckedCount(findings){
+		var uncheckedCount = 0;
+		for (var i=0; i < findings.length; i++){
+			if (findings[i] == ""){
+				uncheckedCount++;
+			}
+		}
+		return uncheckedCount;
+	}
+
+	// Accepted risks don't count against the score, as either a pass or a fail
+	function GetAcceptedCount(findings){
+		var acceptedCount = 0;
+		for (var i=0; i < findings.length; i++){
+			if (findings[i] == "Accepted Risk"){
+				acceptedCount++;
+			}
+		}
+		return acceptedCount;
+	}
+
+	var section1 = Array({{ (index .Checks "Finding 1.1").Status.Open }},{{ (index .Checks "Finding 1.2").Status.Open }},{{(index .Checks "Finding 1.3").Status.Open}},{{ (index .Checks "Finding 1.4").Status.Open }},{{ (index .Checks "Finding 1.5").Status.Open }},{{ (index .Checks "Finding 1.6").Status.Open }},{{ (index .Checks "Finding 1.7").Status.Open }},{{ (index .Checks "Finding 1.8").Status.Open }},{{ (index .Checks "Finding 1.9").Status.Open }},{{ (index .Checks "Finding 1.10").Status.Open }},{{ (index .Checks "Finding 1.11").Status.Open }},{{ (index .Checks "Finding 1.12").Status.Open }},{{ (index .Checks "Finding 1.13").Status.Open }},{{ (index .Checks "Finding 1.14").Status.Open }},{{ (index .Checks "Finding 1.15").Status.Open }})
 	var section1PassCount = GetCount(section1)
-	var section1FailCount = (section1.length - section1PassCount) - 1 // 1 'permanently not checked'
+	var section1AcceptedCount = GetAcceptedCount(section1)
+	var section1UncheckedCount = GetUncheckedCount(section1)
+	var section1FailCount = section1.length - section1PassCount - section1AcceptedCount - section1UncheckedCount
 
-	var section2 = Array({{ (index . "Finding 2.1").Status.Open }},{{ (index . "Finding 2.2").Status.Open }},{{ (index . "Finding 2.3").Status.Open }},{{ (index . "Finding 2.4").Status.Open }},{{ (index . "Finding 2.5").Status.Open }},{{ (index . "Finding 2.6").Status.Open }},{{ (index . "Finding 2.7").Status.Open }},{{ (index . "Finding 2.8").Status.Open }})
+	var section2 = Array({{ (index .Checks "Finding 2.1").Status.Open }},{{ (index .Checks "Finding 2.2").Status.Open }},{{ (index .Checks "Finding 2.3").Status.Open }},{{ (index .Checks "Finding 2.4").Status.Open }},{{ (index .Checks "Finding 2.5").Status.Open }},{{ (index .Checks "Finding 2.6").Status.Open }},{{ (index .Checks "Finding 2.7").Status.Open }},{{ (index .Checks "Finding 2.8").Status.Open }})
 	var section2PassCount = GetCount(section2)
-	var section2FailCount = section2.length - section2PassCount
+	var section2AcceptedCount = GetAcceptedCount(section2)
+	var section2UncheckedCount = GetUncheckedCount(section2)
+	var section2FailCount = section2.length - section2PassCount - section2AcceptedCount - section2UncheckedCount
 
-	var section3 = Array({{( index . "Finding 3.1").Status.Open}},{{( index . "Finding 3.2").Status.Open}},{{( index . "Finding 3.3").Status.Open}},{{( index . "Finding 3.4").Status.Open}},{{( index . "Finding 3.5").Status.Open}},{{( index . "Finding 3.6").Status.Open}},
-		{{( index . "Finding 3.7").Status.Open}},{{( index . "Finding 3.8").Status.Open}},{{( index . "Finding 3.9").Status.Open}},{{( index . "Finding 3.10").Status.Open}},{{( index . "Finding 3.11").Status.Open}},{{( index . "Finding 3.12").Status.Open}},{{( index . "Finding 3.13").Status.Open}},
-		{{( index . "Finding 3.14").Status.Open}},{{( index . "Finding 3.15").Status.Open}},{{( index . "Finding 3.16").Status.Open}})
+	var section3 = Array({{( index .Checks "Finding 3.1").Status.Open}},{{( index .Checks "Finding 3.2").Status.Open}},{{( index .Checks "Finding 3.3").Status.Open}},{{( index .Checks "Finding 3.4").Status.Open}},{{( index .Checks "Finding 3.5").Status.Open}},{{( index .Checks "Finding 3.6").Status.Open}},
+		{{( index .Checks "Finding 3.7").Status.Open}},{{( index .Checks "Finding 3.8").Status.Open}},{{( index .Checks "Finding 3.9").Status.Open}},{{( index .Checks "Finding 3.10").Status.Open}},{{( index .Checks "Finding 3.11").Status.Open}},{{( index .Checks "Finding 3.12").Status.Open}},{{( index .Checks "Finding 3.13").Status.Open}},
+		{{( index .Checks "Finding 3.14").Status.Open}},{{( index .Checks "Finding 3.15").Status.Open}},{{( index .Checks "Finding 3.16").Status.Open}})
 	var section3PassCount = GetCount(section3)
-	var section3FailCount = (section3.length - section3PassCount) - 2 // 2 'permanently not checked'
+	var section3AcceptedCount = GetAcceptedCount(section3)
+	var section3UncheckedCount = GetUncheckedCount(section3)
+	var section3FailCount = section3.length - section3PassCount - section3AcceptedCount - section3UncheckedCount
 
-	var section4 = Array({{( index . "Finding 4.1").Status.Open }},{{( index . "Finding 4.2").Status.Open }},{{( index . "Finding 4.3").Status.Open }},{{( index . "Finding 4.4").Status.Open }})
+	var section4 = Array({{( index .Checks "Finding 4.1").Status.Open }},{{( index .Checks "Finding 4.2").Status.Open }},{{( index .Checks "Finding 4.3").Status.Open }},{{( index .Checks "Finding 4.4").Status.Open }})
 	var section4PassCount = GetCount(section4)
-	var section4FailCount = section4.length - section4PassCount
+	var section4AcceptedCount = GetAcceptedCount(section4)
+	var section4UncheckedCount = GetUncheckedCount(section4)
+	var section4FailCount = section4.length - section4PassCount - section4AcceptedCount - section4UncheckedCount
 </script>
 
 
@@ -92,6 +137,7 @@ const ReportTemplateHTML = `<!DOCTYPE html>
        <ul class="nav navbar-nav">
          <li class="active"><a href="#">Report</a></li>
          <li><a href="https://d0.awsstatic.com/whitepapers/compliance/AWS_CIS_Foundations_Benchmark.pdf">Reference</a></li>
+         <li><a href="#trend">Trend</a></li>
          <li><a href="#about">About</a></li>
          <li><a href="https://www.github.com/adamcrosby/aws-cis-scanner">Github</a></li>
        </ul>
@@ -111,20 +157,23 @@ const ReportTemplateHTML = `<!DOCTYPE html>
 		    labels: [
 		        "Fail",
 		        "Pass",
-		        "Unchecked"
+		        "Unchecked",
+		        "Accepted Risk"
 		    ],
 		    datasets: [
 		        {
-		            data: [section1FailCount, section1PassCount, 1],
+		            data: [section1FailCount, section1PassCount, section1UncheckedCount, section1AcceptedCount],
 		            backgroundColor: [
 		                "#FF6384",
 		                "rgb(92, 184, 92)",
-		                "#FFCE56"
+		                "#FFCE56",
+		                "rgb(91, 192, 222)"
 		            ],
 		            hoverBackgroundColor: [
 		                "#FF6384",
 		                "rgb(92, 184, 92)",
-		                "#FFCE56"
+		                "#FFCE56",
+		                "rgb(91, 192, 222)"
 		            ]
 		        }]
 		};
@@ -152,20 +201,23 @@ const ReportTemplateHTML = `<!DOCTYPE html>
 		    labels: [
 		        "Fail",
 		        "Pass",
-		        "Unchecked"
+		        "Unchecked",
+		        "Accepted Risk"
 		    ],
 		    datasets: [
 		        {
-		            data: [section2FailCount, section2PassCount, 0],
+		            data: [section2FailCount, section2PassCount, section2UncheckedCount, section2AcceptedCount],
 		            backgroundColor: [
 		                "#FF6384",
 		                "rgb(92, 184, 92)",
-		                "#FFCE56"
+		                "#FFCE56",
+		                "rgb(91, 192, 222)"
 		            ],
 		            hoverBackgroundColor: [
 		                "#FF6384",
 		                "rgb(92, 184, 92)",
-		                "#FFCE56"
+		                "#FFCE56",
+		                "rgb(91, 192, 222)"
 		            ]
 		        }]
 		};
@@ -193,20 +245,23 @@ const ReportTemplateHTML = `<!DOCTYPE html>
 		    labels: [
 		        "Fail",
 		        "Pass",
-		        "Unchecked"
+		        "Unchecked",
+		        "Accepted Risk"
 		    ],
 		    datasets: [
 		        {
-		            data: [section3FailCount, section3PassCount, 2],
+		            data: [section3FailCount, section3PassCount, section3UncheckedCount, section3AcceptedCount],
 		            backgroundColor: [
 		                "#FF6384",
 		                "rgb(92, 184, 92)",
-		                "#FFCE56"
+		                "#FFCE56",
+		                "rgb(91, 192, 222)"
 		            ],
 		            hoverBackgroundColor: [
 		                "#FF6384",
 		                "rgb(92, 184, 92)",
-		                "#FFCE56"
+		                "#FFCE56",
+		                "rgb(91, 192, 222)"
 		            ]
 		        }]
 		};
@@ -235,20 +290,23 @@ const ReportTemplateHTML = `<!DOCTYPE html>
 		    labels: [
 		        "Fail",
 		        "Pass",
-		        "Unchecked"
+		        "Unchecked",
+		        "Accepted Risk"
 		    ],
 		    datasets: [
 		        {
-		            data: [section4FailCount, section4PassCount, 0],
+		            data: [section4FailCount, section4PassCount, section4UncheckedCount, section4AcceptedCount],
 		            backgroundColor: [
 		                "#FF6384",
 		                "rgb(92, 184, 92)",
-		                "#FFCE56"
+		                "#FFCE56",
+		                "rgb(91, 192, 222)"
 		            ],
 		            hoverBackgroundColor: [
 		                "#FF6384",
 		                "rgb(92, 184, 92)",
-		                "#FFCE56"
+		                "#FFCE56",
+		                "rgb(91, 192, 222)"
 		            ]
 		        }]
 		};
@@ -274,6 +332,7 @@ const ReportTemplateHTML = `<!DOCTYPE html>
 Fail: <span style="background: #FF6384;">&nbsp;&nbsp;&nbsp;&nbsp;&nbsp;</span>&nbsp;&nbsp;&nbsp;&nbsp;&nbsp;
 Pass: <span style="background: rgb(92, 184, 92);">&nbsp;&nbsp;&nbsp;&nbsp;&nbsp;</span>&nbsp;&nbsp;&nbsp;&nbsp;&nbsp;
 Unchecked: <span style="background: #FFCE56;">&nbsp;&nbsp;&nbsp;&nbsp;&nbsp;</span>&nbsp;&nbsp;&nbsp;&nbsp;&nbsp;
+Accepted Risk: <span style="background: rgb(91, 192, 222);">&nbsp;&nbsp;&nbsp;&nbsp;&nbsp;</span>&nbsp;&nbsp;&nbsp;&nbsp;&nbsp;
 </h4>
 </div>
 
@@ -286,21 +345,21 @@ Unchecked: <span style="background: #FFCE56;">&nbsp;&nbsp;&nbsp;&nbsp;&nbsp;</sp
 <tr><th width="10%">Finding</th><th width="10%">Status</th><th>Title</th><th>Notes</th></tr>
 </thead>
 <tbody >
-<tr><td>Finding 1.1</td><td>{{ (index . "Finding 1.1").Status.Open | statusReplace}}</td><td>Avoid the use of the 'root' account (Scored)</td><td>{{ (index (index . "Finding 1.1").Notes "User") }}</td></tr>
- <tr><td>Finding 1.2</td><td>{{ (index . "Finding 1.2").Status.Open | statusReplace }}</td><td>Ensure multi-factor authentication (MFA) is enabled for all IAM users that have a console password (Scored)</td><td>{{ (index (index . "Finding 1.2").Notes "User") }}</td></tr>
- <tr><td>Finding 1.3</td><td>{{ (index . "Finding 1.3").Status.Open | statusReplace }}</td><td>Ensure credentials unused for 90 days or greater are disabled (Scored)</td><td>{{ (index (index . "Finding 1.3").Notes "User") }}</td></tr>
- <tr><td>Finding 1.4</td><td>{{ (index . "Finding 1.4").Status.Open | statusReplace }}</td><td>Ensure access keys are rotated every 90 days or less (Scored)</td><td>{{ (index (index . "Finding 1.4").Notes "User") }}</td></tr>
- <tr><td>Finding 1.5</td><td>{{ (index . "Finding 1.5").Status.Open | statusReplace }}</td><td>Ensure IAM password policy requires at least one uppercase letter (Scored)</td><td>{{ (index (index . "Finding 1.5").Notes "User") }}</td></tr>
- <tr><td>Finding 1.6</td><td>{{ (index . "Finding 1.6").Status.Open | statusReplace }}</td><td>Ensure IAM password policy require at least one lowercase letter (Scored)</td><td>{{ (index (index . "Finding 1.6").Notes "User") }}</td></tr>
- <tr><td>Finding 1.7</td><td>{{ (index . "Finding 1.7").Status.Open | statusReplace }}</td><td>Ensure IAM password policy require at least one symbol (Scored)</td><td>{{ (index (index . "Finding 1.7").Notes "User") }}</td></tr>
- <tr><td>Finding 1.8</td><td>{{ (index . "Finding 1.8").Status.Open | statusReplace }}</td><td>Ensure IAM password policy require at least one number (Scored)</td><td>{{ (index (index . "Finding 1.8").Notes "User") }}</td></tr>
- <tr><td>Finding 1.9</td><td>{{ (index . "Finding 1.9").Status.Open | statusReplace }}</td><td>Ensure IAM password policy requires minimum length of 14 or greater (Scored)</td><td>{{ (index (index . "Finding 1.9").Notes "User") }}</td></tr>
- <tr><td>Finding 1.10</td><td>{{ (index . "Finding 1.10").Status.Open | statusReplace }}</td><td>Ensure IAM password policy prevents password reuse (Scored)</td><td>{{ (index (index . "Finding 1.10").Notes "User") }}</td></tr>
- <tr><td>Finding 1.11</td><td>{{ (index . "Finding 1.11").Status.Open | statusReplace }}</td><td>Ensure IAM password policy expires passwords within 90 days or less (Scored)</td><td>{{ (index (index . "Finding 1.11").Notes "User") }}</td></tr>
- <tr><td>Finding 1.12</td><td>{{ (index . "Finding 1.12").Status.Open | statusReplace }}</td><td>Ensure no root account access key exists (Scored)</td><td>{{ (index (index . "Finding 1.12").Notes "User") }}</td></tr>
- <tr><td>Finding 1.13</td><td>{{ (index . "Finding 1.13").Status.Open | statusReplace }}</td><td>Ensure hardware MFA is enabled for the 'root' account (Scored)</td><td>{{ (index (index . "Finding 1.13").Notes "User") }}</td></tr>
+<tr><td>Finding 1.1</td><td>{{ (index .Checks "Finding 1.1").Status.Open | statusReplace}}</td><td>Avoid the use of the 'root' account (Scored)</td><td>{{ (index (index .Checks "Finding 1.1").Notes "User") }}{{ with (index (index .Checks "Finding 1.1").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 1.1") }}</td></tr>
+ <tr><td>Finding 1.2</td><td>{{ (index .Checks "Finding 1.2").Status.Open | statusReplace }}</td><td>Ensure multi-factor authentication (MFA) is enabled for all IAM users that have a console password (Scored)</td><td>{{ (index (index .Checks "Finding 1.2").Notes "User") }}{{ with (index (index .Checks "Finding 1.2").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 1.2") }}</td></tr>
+ <tr><td>Finding 1.3</td><td>{{ (index .Checks "Finding 1.3").Status.Open | statusReplace }}</td><td>Ensure credentials unused for 90 days or greater are disabled (Scored)</td><td>{{ (index (index .Checks "Finding 1.3").Notes "User") }}{{ with (index (index .Checks "Finding 1.3").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 1.3") }}</td></tr>
+ <tr><td>Finding 1.4</td><td>{{ (index .Checks "Finding 1.4").Status.Open | statusReplace }}</td><td>Ensure access keys are rotated every 90 days or less (Scored)</td><td>{{ (index (index .Checks "Finding 1.4").Notes "User") }}{{ with (index (index .Checks "Finding 1.4").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 1.4") }}</td></tr>
+ <tr><td>Finding 1.5</td><td>{{ (index .Checks "Finding 1.5").Status.Open | statusReplace }}</td><td>Ensure IAM password policy requires at least one uppercase letter (Scored)</td><td>{{ (index (index .Checks "Finding 1.5").Notes "User") }}{{ with (index (index .Checks "Finding 1.5").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 1.5") }}</td></tr>
+ <tr><td>Finding 1.6</td><td>{{ (index .Checks "Finding 1.6").Status.Open | statusReplace }}</td><td>Ensure IAM password policy require at least one lowercase letter (Scored)</td><td>{{ (index (index .Checks "Finding 1.6").Notes "User") }}{{ with (index (index .Checks "Finding 1.6").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 1.6") }}</td></tr>
+ <tr><td>Finding 1.7</td><td>{{ (index .Checks "Finding 1.7").Status.Open | statusReplace }}</td><td>Ensure IAM password policy require at least one symbol (Scored)</td><td>{{ (index (index .Checks "Finding 1.7").Notes "User") }}{{ with (index (index .Checks "Finding 1.7").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 1.7") }}</td></tr>
+ <tr><td>Finding 1.8</td><td>{{ (index .Checks "Finding 1.8").Status.Open | statusReplace }}</td><td>Ensure IAM password policy require at least one number (Scored)</td><td>{{ (index (index .Checks "Finding 1.8").Notes "User") }}{{ with (index (index .Checks "Finding 1.8").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 1.8") }}</td></tr>
+ <tr><td>Finding 1.9</td><td>{{ (index .Checks "Finding 1.9").Status.Open | statusReplace }}</td><td>Ensure IAM password policy requires minimum length of 14 or greater (Scored)</td><td>{{ (index (index .Checks "Finding 1.9").Notes "User") }}{{ with (index (index .Checks "Finding 1.9").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 1.9") }}</td></tr>
+ <tr><td>Finding 1.10</td><td>{{ (index .Checks "Finding 1.10").Status.Open | statusReplace }}</td><td>Ensure IAM password policy prevents password reuse (Scored)</td><td>{{ (index (index .Checks "Finding 1.10").Notes "User") }}{{ with (index (index .Checks "Finding 1.10").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 1.10") }}</td></tr>
+ <tr><td>Finding 1.11</td><td>{{ (index .Checks "Finding 1.11").Status.Open | statusReplace }}</td><td>Ensure IAM password policy expires passwords within 90 days or less (Scored)</td><td>{{ (index (index .Checks "Finding 1.11").Notes "User") }}{{ with (index (index .Checks "Finding 1.11").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 1.11") }}</td></tr>
+ <tr><td>Finding 1.12</td><td>{{ (index .Checks "Finding 1.12").Status.Open | statusReplace }}</td><td>Ensure no root account access key exists (Scored)</td><td>{{ (index (index .Checks "Finding 1.12").Notes "User") }}{{ with (index (index .Checks "Finding 1.12").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 1.12") }}</td></tr>
+ <tr><td>Finding 1.13</td><td>{{ (index .Checks "Finding 1.13").Status.Open | statusReplace }}</td><td>Ensure hardware MFA is enabled for the 'root' account (Scored)</td><td>{{ (index (index .Checks "Finding 1.13").Notes "User") }}{{ with (index (index .Checks "Finding 1.13").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 1.13") }}</td></tr>
  <tr><td>Finding 1.14</td><td><h3 class="label label-warning">Not Checked</h3></td><td>Ensure security questions are registered in the AWS account (Not Scored) </td><td><span class="label label-info"><a href="#note1">Note 1</span></td></tr>
- <tr><td>Finding 1.15</td><td>{{ (index . "Finding 1.15").Status.Open | statusReplace }}</td><td>Ensure IAM policies are attached only to groups or roles (Scored)</td><td>{{ (index (index . "Finding 1.15").Notes "User") }}</td></tr>
+ <tr><td>Finding 1.15</td><td>{{ (index .Checks "Finding 1.15").Status.Open | statusReplace }}</td><td>Ensure IAM policies are attached only to groups or roles (Scored)</td><td>{{ (index (index .Checks "Finding 1.15").Notes "User") }}{{ with (index (index .Checks "Finding 1.15").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 1.15") }}</td></tr>
 </tbody>
 </table>
 
@@ -311,14 +370,14 @@ Unchecked: <span style="background: #FFCE56;">&nbsp;&nbsp;&nbsp;&nbsp;&nbsp;</sp
 <tr><th width="10%">Finding</th><th width="10%">Status</th><th>Title</th><th>Notes</th></tr>
 </thead>
 <tbody>
- <tr><td>Finding 2.1</td><td>{{	(index . "Finding 2.1").Status.Open | statusReplace }}</td><td>Ensure CloudTrail is enabled in all regions (Scored)</td><td>{{ ( index (index . "Finding 2.1").Notes "User")}}</td></tr>
- <tr><td>Finding 2.2</td><td>{{	(index . "Finding 2.2").Status.Open | statusReplace }}</td><td>Ensure CloudTrail log file validation is enabled (Scored)</td><td>{{ ( index (index . "Finding 2.2").Notes "User")}}</td></tr>
- <tr><td>Finding 2.3</td><td>{{	(index . "Finding 2.3").Status.Open | statusReplace }}</td><td>Ensure the S3 bucket CloudTrail logs to is not publicly accessible (Scored)</td><td>{{ ( index (index . "Finding 2.3").Notes "User")}}</td></tr>
- <tr><td>Finding 2.4</td><td>{{	(index . "Finding 2.4").Status.Open | statusReplace }}</td><td>Ensure CloudTrail trails are integrated with CloudWatch Logs (Scored)</td><td>{{ ( index (index . "Finding 2.4").Notes "User")}}</td></tr>
- <tr><td>Finding 2.5</td><td>{{	(index . "Finding 2.5").Status.Open | statusReplace }}</td><td>Ensure AWS Config is enabled in all regions (Scored)</td><td>{{ ( index (index . "Finding 2.5").Notes "User")}}</td></tr>
- <tr><td>Finding 2.6</td><td>{{	(index . "Finding 2.6").Status.Open | statusReplace }}</td><td>Ensure S3 bucket access logging is enabled on the CloudTrail S3 bucket (Scored)</td><td>{{ ( index (index . "Finding 2.6").Notes "User")}}</td></tr>
- <tr><td>Finding 2.7</td><td>{{	(index . "Finding 2.7").Status.Open | statusReplace }}</td><td>Ensure CloudTrail logs are encrypted at rest using KMS CMKs (Scored)</td><td>{{ ( index (index . "Finding 2.7").Notes "User")}}</td></tr>
- <tr><td>Finding 2.8</td><td>{{	(index . "Finding 2.8").Status.Open | statusReplace }}</td><td>Ensure rotation for customer created CMKs is enabled (Scored)</td><td>{{ ( index (index . "Finding 2.8").Notes "User")}}</td></tr>
+ <tr><td>Finding 2.1</td><td>{{	(index .Checks "Finding 2.1").Status.Open | statusReplace }}</td><td>Ensure CloudTrail is enabled in all regions (Scored)</td><td>{{ ( index (index .Checks "Finding 2.1").Notes "User")}}{{ with (index (index .Checks "Finding 2.1").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 2.1") }}</td></tr>
+ <tr><td>Finding 2.2</td><td>{{	(index .Checks "Finding 2.2").Status.Open | statusReplace }}</td><td>Ensure CloudTrail log file validation is enabled (Scored)</td><td>{{ ( index (index .Checks "Finding 2.2").Notes "User")}}{{ with (index (index .Checks "Finding 2.2").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 2.2") }}</td></tr>
+ <tr><td>Finding 2.3</td><td>{{	(index .Checks "Finding 2.3").Status.Open | statusReplace }}</td><td>Ensure the S3 bucket CloudTrail logs to is not publicly accessible (Scored)</td><td>{{ ( index (index .Checks "Finding 2.3").Notes "User")}}{{ with (index (index .Checks "Finding 2.3").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 2.3") }}</td></tr>
+ <tr><td>Finding 2.4</td><td>{{	(index .Checks "Finding 2.4").Status.Open | statusReplace }}</td><td>Ensure CloudTrail trails are integrated with CloudWatch Logs (Scored)</td><td>{{ ( index (index .Checks "Finding 2.4").Notes "User")}}{{ with (index (index .Checks "Finding 2.4").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 2.4") }}</td></tr>
+ <tr><td>Finding 2.5</td><td>{{	(index .Checks "Finding 2.5").Status.Open | statusReplace }}</td><td>Ensure AWS Config is enabled in all regions (Scored)</td><td>{{ ( index (index .Checks "Finding 2.5").Notes "User")}}{{ with (index (index .Checks "Finding 2.5").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 2.5") }}</td></tr>
+ <tr><td>Finding 2.6</td><td>{{	(index .Checks "Finding 2.6").Status.Open | statusReplace }}</td><td>Ensure S3 bucket access logging is enabled on the CloudTrail S3 bucket (Scored)</td><td>{{ ( index (index .Checks "Finding 2.6").Notes "User")}}{{ with (index (index .Checks "Finding 2.6").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 2.6") }}</td></tr>
+ <tr><td>Finding 2.7</td><td>{{	(index .Checks "Finding 2.7").Status.Open | statusReplace }}</td><td>Ensure CloudTrail logs are encrypted at rest using KMS CMKs (Scored)</td><td>{{ ( index (index .Checks "Finding 2.7").Notes "User")}}{{ with (index (index .Checks "Finding 2.7").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 2.7") }}</td></tr>
+ <tr><td>Finding 2.8</td><td>{{	(index .Checks "Finding 2.8").Status.Open | statusReplace }}</td><td>Ensure rotation for customer created CMKs is enabled (Scored)</td><td>{{ ( index (index .Checks "Finding 2.8").Notes "User")}}{{ with (index (index .Checks "Finding 2.8").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 2.8") }}</td></tr>
 </tbody>
 </table>
 
@@ -328,22 +387,22 @@ Unchecked: <span style="background: #FFCE56;">&nbsp;&nbsp;&nbsp;&nbsp;&nbsp;</sp
 <tr><th width="10%">Finding</th><th width="10%">Status</th><th>Title</th><th>Notes</th></tr>
 </thead>
 <tbody>
- <tr><td>Finding 3.1  </td><td>{{	(index . "Finding 3.1").Status.Open  | statusReplace }}</td><td>Ensure a log metric filter and alarm exist for unauthorized API calls (Scored)</td><td>{{ ( index (index . "Finding 3.1").Notes "User") }}</td></tr>
- <tr><td>Finding 3.2  </td><td>{{	(index . "Finding 3.2").Status.Open  | statusReplace }}</td><td>Ensure a log metric filter and alarm exist for Management Console sign-in without MFA (Scored)</td><td>{{ ( index (index . "Finding 3.2").Notes "User") }}</td></tr>
- <tr><td>Finding 3.3  </td><td>{{	(index . "Finding 3.3").Status.Open  | statusReplace }}</td><td>Ensure a log metric filter and alarm exist for usage of 'root' account (Scored)</td><td>{{ ( index (index . "Finding 3.3").Notes "User") }}</td></tr>
- <tr><td>Finding 3.4  </td><td>{{	(index . "Finding 3.4").Status.Open  | statusReplace }}</td><td>Ensure a log metric filter and alarm exist for IAM policy changes (Scored)</td><td>{{ ( index (index . "Finding 3.4").Notes "User") }}</td></tr>
- <tr><td>Finding 3.5  </td><td>{{	(index . "Finding 3.5").Status.Open  | statusReplace }}</td><td>Ensure a log metric filter and alarm exist for CloudTrail configuration changes</td><td>{{ ( index (index . "Finding 3.5").Notes "User") }}</td></tr>
- <tr><td>Finding 3.6  </td><td>{{	(index . "Finding 3.6").Status.Open  | statusReplace }}</td><td>Ensure a log metric filter and alarm exist for AWS Management Console authentication failures (Scored)</td><td>{{ ( index (index . "Finding 3.6").Notes "User") }}</td></tr>
- <tr><td>Finding 3.7  </td><td>{{	(index . "Finding 3.7").Status.Open  | statusReplace }}</td><td>Ensure a log metric filter and alarm exist for disabling or scheduled deletion of customer created CMKs (Scored)</td><td>{{ ( index (index . "Finding 3.7").Notes "User") }}</td></tr>
- <tr><td>Finding 3.8  </td><td>{{	(index . "Finding 3.8").Status.Open  | statusReplace }}</td><td>Ensure a log metric filter and alarm exist for S3 bucket policy changes (Scored)</td><td>{{ ( index (index . "Finding 3.8").Notes "User") }}</td></tr>
- <tr><td>Finding 3.9  </td><td>{{	(index . "Finding 3.9").Status.Open  | statusReplace }}</td><td>Ensure a log metric filter and alarm exist for AWS Config configuration changes</td><td>{{ ( index (index . "Finding 3.9").Notes "User") }}</td></tr>
- <tr><td>Finding 3.10 </td><td>{{	(index . "Finding 3.10").Status.Open | statusReplace }}</td><td>Ensure a log metric filter and alarm exist for security group changes (Scored)</td><td>{{ ( index (index . "Finding 3.10").Notes "User") }}</td></tr>
- <tr><td>Finding 3.11 </td><td>{{	(index . "Finding 3.11").Status.Open | statusReplace }}</td><td>Ensure a log metric filter and alarm exist for changes to Network Access Control Lists (NACL) (Scored)</td><td>{{ ( index (index . "Finding 3.11").Notes "User") }}</td></tr>
- <tr><td>Finding 3.12 </td><td>{{	(index . "Finding 3.12").Status.Open | statusReplace }}</td><td>Ensure a log metric filter and alarm exist for changes to network gateways</td><td>{{ ( index (index . "Finding 3.12").Notes "User") }}</td></tr>
- <tr><td>Finding 3.13 </td><td>{{	(index . "Finding 3.13").Status.Open | statusReplace }}</td><td>Ensure a log metric filter and alarm exist for route table changes (Scored)</td><td>{{ ( index (index . "Finding 3.13").Notes "User") }}</td></tr>
- <tr><td>Finding 3.14 </td><td>{{	(index . "Finding 3.14").Status.Open | statusReplace }}</td><td>Ensure a log metric filter and alarm exist for VPC changes (Scored)</td><td>{{ ( index (index . "Finding 3.14").Notes "User") }}</td></tr>
- <tr><td>Finding 3.15 </td><td><h3 class="label label-warning">Not Checked</h3></td><td>Ensure security contact information is registered (Scored) </td><td><span class="label label-info"><a href="#note1">Note 1</span></td></tr>
- <tr><td>Finding 3.16 </td><td><h3 class="label label-warning">Not Checked</h3></td><td>Ensure appropriate subscribers to each SNS topic (Not Scored) </td><td><span class="label label-info"><a href="#note2">Note 2</span></td></tr>
+ <tr><td>Finding 3.1  </td><td>{{	(index .Checks "Finding 3.1").Status.Open  | statusReplace }}</td><td>Ensure a log metric filter and alarm exist for unauthorized API calls (Scored)</td><td>{{ ( index (index .Checks "Finding 3.1").Notes "User") }}{{ with (index (index .Checks "Finding 3.1").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 3.1") }}</td></tr>
+ <tr><td>Finding 3.2  </td><td>{{	(index .Checks "Finding 3.2").Status.Open  | statusReplace }}</td><td>Ensure a log metric filter and alarm exist for Management Console sign-in without MFA (Scored)</td><td>{{ ( index (index .Checks "Finding 3.2").Notes "User") }}{{ with (index (index .Checks "Finding 3.2").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 3.2") }}</td></tr>
+ <tr><td>Finding 3.3  </td><td>{{	(index .Checks "Finding 3.3").Status.Open  | statusReplace }}</td><td>Ensure a log metric filter and alarm exist for usage of 'root' account (Scored)</td><td>{{ ( index (index .Checks "Finding 3.3").Notes "User") }}{{ with (index (index .Checks "Finding 3.3").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 3.3") }}</td></tr>
+ <tr><td>Finding 3.4  </td><td>{{	(index .Checks "Finding 3.4").Status.Open  | statusReplace }}</td><td>Ensure a log metric filter and alarm exist for IAM policy changes (Scored)</td><td>{{ ( index (index .Checks "Finding 3.4").Notes "User") }}{{ with (index (index .Checks "Finding 3.4").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 3.4") }}</td></tr>
+ <tr><td>Finding 3.5  </td><td>{{	(index .Checks "Finding 3.5").Status.Open  | statusReplace }}</td><td>Ensure a log metric filter and alarm exist for CloudTrail configuration changes</td><td>{{ ( index (index .Checks "Finding 3.5").Notes "User") }}{{ with (index (index .Checks "Finding 3.5").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 3.5") }}</td></tr>
+ <tr><td>Finding 3.6  </td><td>{{	(index .Checks "Finding 3.6").Status.Open  | statusReplace }}</td><td>Ensure a log metric filter and alarm exist for AWS Management Console authentication failures (Scored)</td><td>{{ ( index (index .Checks "Finding 3.6").Notes "User") }}{{ with (index (index .Checks "Finding 3.6").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 3.6") }}</td></tr>
+ <tr><td>Finding 3.7  </td><td>{{	(index .Checks "Finding 3.7").Status.Open  | statusReplace }}</td><td>Ensure a log metric filter and alarm exist for disabling or scheduled deletion of customer created CMKs (Scored)</td><td>{{ ( index (index .Checks "Finding 3.7").Notes "User") }}{{ with (index (index .Checks "Finding 3.7").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 3.7") }}</td></tr>
+ <tr><td>Finding 3.8  </td><td>{{	(index .Checks "Finding 3.8").Status.Open  | statusReplace }}</td><td>Ensure a log metric filter and alarm exist for S3 bucket policy changes (Scored)</td><td>{{ ( index (index .Checks "Finding 3.8").Notes "User") }}{{ with (index (index .Checks "Finding 3.8").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 3.8") }}</td></tr>
+ <tr><td>Finding 3.9  </td><td>{{	(index .Checks "Finding 3.9").Status.Open  | statusReplace }}</td><td>Ensure a log metric filter and alarm exist for AWS Config configuration changes</td><td>{{ ( index (index .Checks "Finding 3.9").Notes "User") }}{{ with (index (index .Checks "Finding 3.9").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 3.9") }}</td></tr>
+ <tr><td>Finding 3.10 </td><td>{{	(index .Checks "Finding 3.10").Status.Open | statusReplace }}</td><td>Ensure a log metric filter and alarm exist for security group changes (Scored)</td><td>{{ ( index (index .Checks "Finding 3.10").Notes "User") }}{{ with (index (index .Checks "Finding 3.10").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 3.10") }}</td></tr>
+ <tr><td>Finding 3.11 </td><td>{{	(index .Checks "Finding 3.11").Status.Open | statusReplace }}</td><td>Ensure a log metric filter and alarm exist for changes to Network Access Control Lists (NACL) (Scored)</td><td>{{ ( index (index .Checks "Finding 3.11").Notes "User") }}{{ with (index (index .Checks "Finding 3.11").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 3.11") }}</td></tr>
+ <tr><td>Finding 3.12 </td><td>{{	(index .Checks "Finding 3.12").Status.Open | statusReplace }}</td><td>Ensure a log metric filter and alarm exist for changes to network gateways</td><td>{{ ( index (index .Checks "Finding 3.12").Notes "User") }}{{ with (index (index .Checks "Finding 3.12").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 3.12") }}</td></tr>
+ <tr><td>Finding 3.13 </td><td>{{	(index .Checks "Finding 3.13").Status.Open | statusReplace }}</td><td>Ensure a log metric filter and alarm exist for route table changes (Scored)</td><td>{{ ( index (index .Checks "Finding 3.13").Notes "User") }}{{ with (index (index .Checks "Finding 3.13").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 3.13") }}</td></tr>
+ <tr><td>Finding 3.14 </td><td>{{	(index .Checks "Finding 3.14").Status.Open | statusReplace }}</td><td>Ensure a log metric filter and alarm exist for VPC changes (Scored)</td><td>{{ ( index (index .Checks "Finding 3.14").Notes "User") }}{{ with (index (index .Checks "Finding 3.14").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 3.14") }}</td></tr>
+ <tr><td>Finding 3.15 </td><td>{{	(index .Checks "Finding 3.15").Status.Open | statusReplace }}</td><td>Ensure security contact information is registered (Scored)</td><td>{{ ( index (index .Checks "Finding 3.15").Notes "User") }}{{ with (index (index .Checks "Finding 3.15").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 3.15") }}</td></tr>
+ <tr><td>Finding 3.16 </td><td>{{	(index .Checks "Finding 3.16").Status.Open | statusReplace }}</td><td>Ensure appropriate subscribers to each SNS topic (Not Scored)</td><td>{{ ( index (index .Checks "Finding 3.16").Notes "User") }} <span class="label label-info"><a href="#note2">Note 2</a></span>{{ with (index (index .Checks "Finding 3.16").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "resources" (index .Checks "Finding 3.16") }}{{ template "remediation" (index .Checks "Finding 3.16") }}</td></tr>
 </tbody>
 </table>
 
@@ -353,13 +412,80 @@ Unchecked: <span style="background: #FFCE56;">&nbsp;&nbsp;&nbsp;&nbsp;&nbsp;</sp
 <tr><th width="10%">Finding</th><th width="10%">Status</th><th>Title</th><th width="40%">Notes</th></tr>
 </thead>
 <tbody>
- <tr><td>Finding 4.1</td><td>{{	(index . "Finding 4.1").Status.Open | statusReplace }}</td><td>Ensure no security groups allow ingress from 0.0.0.0/0 to port 22 (Scored)</td><td>{{ ( index (index . "Finding 4.1").Notes "User") }}</td></tr>
- <tr><td>Finding 4.2</td><td>{{	(index . "Finding 4.2").Status.Open | statusReplace }}</td><td>Ensure no security groups allow ingress from 0.0.0.0/0 to port 3389 (Scored)</td><td>{{ ( index (index . "Finding 4.2").Notes "User") }}</td></tr>
- <tr><td>Finding 4.3</td><td>{{	(index . "Finding 4.3").Status.Open | statusReplace }}</td><td>Ensure VPC Flow Logging is Enabled in all Applicable Regions (Scored)</td><td>{{ ( index (index . "Finding 4.3").Notes "User") }}</td></tr>
- <tr><td>Finding 4.4</td><td>{{	(index . "Finding 4.4").Status.Open | statusReplace }}</td><td>Ensure the default security group restricts all traffic (Scored)</td><td>{{ ( index (index . "Finding 4.4").Notes "User") }}</td></tr>
+ <tr><td>Finding 4.1</td><td>{{	(index .Checks "Finding 4.1").Status.Open | statusReplace }}</td><td>Ensure no security groups allow ingress from 0.0.0.0/0 to port 22 (Scored)</td><td>{{ ( index (index .Checks "Finding 4.1").Notes "User") }}{{ with (index (index .Checks "Finding 4.1").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 4.1") }}</td></tr>
+ <tr><td>Finding 4.2</td><td>{{	(index .Checks "Finding 4.2").Status.Open | statusReplace }}</td><td>Ensure no security groups allow ingress from 0.0.0.0/0 to port 3389 (Scored)</td><td>{{ ( index (index .Checks "Finding 4.2").Notes "User") }}{{ with (index (index .Checks "Finding 4.2").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 4.2") }}</td></tr>
+ <tr><td>Finding 4.3</td><td>{{	(index .Checks "Finding 4.3").Status.Open | statusReplace }}</td><td>Ensure VPC Flow Logging is Enabled in all Applicable Regions (Scored)</td><td>{{ ( index (index .Checks "Finding 4.3").Notes "User") }}{{ with (index (index .Checks "Finding 4.3").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 4.3") }}</td></tr>
+ <tr><td>Finding 4.4</td><td>{{	(index .Checks "Finding 4.4").Status.Open | statusReplace }}</td><td>Ensure the default security group restricts all traffic (Scored)</td><td>{{ ( index (index .Checks "Finding 4.4").Notes "User") }}{{ with (index (index .Checks "Finding 4.4").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 4.4") }}</td></tr>
+</tbody>
+</table>
+
+<h1>Section 5: Extended checks</h1>
+<table class="table table-striped table-hover table-condensed">
+<thead>
+<tr><th width="10%">Finding</th><th width="10%">Status</th><th>Title</th><th width="40%">Notes</th></tr>
+</thead>
+<tbody>
+ <tr><td>Finding 5.1</td><td>{{	(index .Checks "Finding 5.1").Status.Open | statusReplace }}</td><td>Ensure no S3 bucket is publicly or cross-account accessible (Extended)</td><td>{{ ( index (index .Checks "Finding 5.1").Notes "User") }}{{ with (index (index .Checks "Finding 5.1").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 5.1") }}</td></tr>
+ <tr><td>Finding 5.2</td><td>{{	(index .Checks "Finding 5.2").Status.Open | statusReplace }}</td><td>Ensure all S3 buckets have default encryption enabled (Extended)</td><td>{{ ( index (index .Checks "Finding 5.2").Notes "User") }}{{ with (index (index .Checks "Finding 5.2").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 5.2") }}</td></tr>
+ <tr><td>Finding 5.3</td><td>{{	(index .Checks "Finding 5.3").Status.Open | statusReplace }}</td><td>Ensure all S3 bucket policies deny requests without TLS (Extended)</td><td>{{ ( index (index .Checks "Finding 5.3").Notes "User") }}{{ with (index (index .Checks "Finding 5.3").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 5.3") }}</td></tr>
+ <tr><td>Finding 5.4</td><td>{{	(index .Checks "Finding 5.4").Status.Open | statusReplace }}</td><td>Ensure versioning is enabled on all S3 buckets (Extended)</td><td>{{ ( index (index .Checks "Finding 5.4").Notes "User") }}{{ with (index (index .Checks "Finding 5.4").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 5.4") }}</td></tr>
+ <tr><td>Finding 5.5</td><td>{{	(index .Checks "Finding 5.5").Status.Open | statusReplace }}</td><td>Ensure MFA Delete is enabled on the CloudTrail S3 buckets (Extended)</td><td>{{ ( index (index .Checks "Finding 5.5").Notes "User") }}{{ with (index (index .Checks "Finding 5.5").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 5.5") }}</td></tr>
+ <tr><td>Finding 5.6</td><td>{{	(index .Checks "Finding 5.6").Status.Open | statusReplace }}</td><td>Ensure no KMS key policy allows access to anyone (Extended)</td><td>{{ ( index (index .Checks "Finding 5.6").Notes "User") }}{{ with (index (index .Checks "Finding 5.6").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 5.6") }}</td></tr>
+ <tr><td>Finding 5.7</td><td>{{	(index .Checks "Finding 5.7").Status.Open | statusReplace }}</td><td>Ensure KMS keys are not shared with untrusted accounts (Extended)</td><td>{{ ( index (index .Checks "Finding 5.7").Notes "User") }}{{ with (index (index .Checks "Finding 5.7").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 5.7") }}</td></tr>
+ <tr><td>Finding 5.8</td><td>{{	(index .Checks "Finding 5.8").Status.Open | statusReplace }}</td><td>Ensure every KMS key policy lets the account manage the key (Extended)</td><td>{{ ( index (index .Checks "Finding 5.8").Notes "User") }}{{ with (index (index .Checks "Finding 5.8").Notes "Waiver") }}<br /><em>{{ . }}</em>{{ end }}{{ template "remediation" (index .Checks "Finding 5.8") }}</td></tr>
 </tbody>
 </table>
 </div>
+{{ if .Trend.Sections }}
+<div class="container">
+<a name="trend"></a>
+<h1>Trend</h1>
+<canvas id="trendChart" width="100" height="30"></canvas>
+	<script>
+		var ctxTrend = document.getElementById("trendChart");
+		var trendChart = new Chart(ctxTrend,{
+		    type: 'line',
+		    data: {
+		        labels: [{{ range .Trend.Sections }}{{ .Timestamp.Format "2006-01-02 15:04" }},{{ end }}],
+		        datasets: [
+		            { label: "Section 1", fill: false, borderColor: "#337ab7", data: [{{ range .Trend.Sections }}{{ if .Has "1" }}{{ index .PassRate "1" }}{{ else }}null{{ end }},{{ end }}] },
+		            { label: "Section 2", fill: false, borderColor: "#5cb85c", data: [{{ range .Trend.Sections }}{{ if .Has "2" }}{{ index .PassRate "2" }}{{ else }}null{{ end }},{{ end }}] },
+		            { label: "Section 3", fill: false, borderColor: "#f0ad4e", data: [{{ range .Trend.Sections }}{{ if .Has "3" }}{{ index .PassRate "3" }}{{ else }}null{{ end }},{{ end }}] },
+		            { label: "Section 4", fill: false, borderColor: "#d9534f", data: [{{ range .Trend.Sections }}{{ if .Has "4" }}{{ index .PassRate "4" }}{{ else }}null{{ end }},{{ end }}] },
+		            { label: "Section 5", fill: false, borderColor: "#5bc0de", data: [{{ range .Trend.Sections }}{{ if .Has "5" }}{{ index .PassRate "5" }}{{ else }}null{{ end }},{{ end }}] }
+		        ]
+		    },
+		    options: {
+		        title: {
+		                display: true,
+		                text: 'Pass rate (%) per section over time',
+		        },
+		        scales: {
+		                yAxes: [{ ticks: { min: 0, max: 100 } }]
+		        }
+		    }
+		});
+	</script>
+<table class="table table-striped table-hover table-condensed">
+<thead>
+<tr><th>Scan</th><th>Section 1</th><th>Section 2</th><th>Section 3</th><th>Section 4</th><th>Section 5</th></tr>
+</thead>
+<tbody>
+{{ range .Trend.Sections }} <tr><td>{{ .Timestamp.Format "2006-01-02 15:04 MST" }}</td><td>{{ if .Has "1" }}{{ index .PassRate "1" }}%{{ else }}-{{ end }}</td><td>{{ if .Has "2" }}{{ index .PassRate "2" }}%{{ else }}-{{ end }}</td><td>{{ if .Has "3" }}{{ index .PassRate "3" }}%{{ else }}-{{ end }}</td><td>{{ if .Has "4" }}{{ index .PassRate "4" }}%{{ else }}-{{ end }}</td><td>{{ if .Has "5" }}{{ index .PassRate "5" }}%{{ else }}-{{ end }}</td></tr>
+{{ end }}</tbody>
+</table>
+
+<h3>Open findings</h3>
+<table class="table table-striped table-hover table-condensed">
+<thead>
+<tr><th width="10%">Finding</th><th>Title</th><th width="20%">Open since</th><th width="10%">Days open</th></tr>
+</thead>
+<tbody>
+{{ range .Trend.Open }} <tr><td>{{ .Name }}</td><td>{{ .Description }}</td><td>{{ .OpenSince.Format "2006-01-02 15:04 MST" }}</td><td>{{ .Days }}</td></tr>
+{{ end }}</tbody>
+</table>
+</div>
+{{ end }}
 <div class="container">
 <ol>
 <li><a name="note1"></a>Note 1: This item is not possible to programatically check/verify</li>
@@ -379,4 +505,19 @@ Unchecked: <span style="background: #FFCE56;">&nbsp;&nbsp;&nbsp;&nbsp;&nbsp;</sp
 
 </body>
 </html>
-`
+{{ define "resources" }}{{ with .Resources }}
+<details><summary><strong>Resources</strong></summary>
+<table class="table table-condensed">
+<tr><th>Region</th><th>Resource</th><th>Status</th><th>Evidence</th></tr>
+{{ range . }}<tr><td>{{ .Region }}</td><td>{{ with .Name }}{{ . }}<br />{{ end }}<small>{{ .ID }}</small></td><td>{{ .Open }}</td><td>{{ .Evidence }}</td></tr>
+{{ end }}</table>
+</details>{{ end }}{{ end }}
+{{ define "remediation" }}{{ with .Remediation }}
+<details><summary><strong>How to fix</strong></summary>
+<p>{{ .Explanation }}</p>
+{{ if .CLI }}<p><strong>AWS CLI</strong></p>
+<pre>{{ range .CLI }}{{ . }}
+{{ end }}</pre>{{ end }}
+{{ if .Console }}<p><strong>Console</strong></p>
+<ol>{{ range .Console }}<li>{{ . }}</li>{{ end }}</ol>{{ end }}
+</details>{{ end }}{{ end }}`
@@ -0,0 +1,122 @@
+package waivers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"time"
+
+	"github.com/adamcrosby/aws-cis-scanner/utility/findings"
+)
+
+// DateFormat is the format waiver expiry dates are written in
+const DateFormat = "2006-01-02"
+
+// Waiver accepts the risk of a failing check.  If Resource is set, only the resources matching
+// it (a shell style pattern, eg: "bastion-*") are accepted, otherwise the whole check is.
+type Waiver struct {
+	Check         string `json:"check"`
+	Resource      string `json:"resource,omitempty"`
+	Justification string `json:"justification"`
+	Approver      string `json:"approver"`
+	Expires       string `json:"expires"`
+}
+
+// File is the layout of a waiver file on disk
+type File struct {
+	Waivers []Waiver `json:"waivers"`
+}
+
+/*
+Load reads and validates a waiver file
+*/
+func Load(filename string) ([]Waiver, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var f File
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	for i, w := range f.Waivers {
+		if w.Check == "" || w.Justification == "" || w.Approver == "" {
+			return nil, fmt.Errorf("%s: waiver %d must have a check, justification and approver", filename, i+1)
+		}
+		if _, err := time.Parse(DateFormat, w.Expires); err != nil {
+			return nil, fmt.Errorf("%s: waiver %d has an invalid expiry date %q (expected YYYY-MM-DD)", filename, i+1, w.Expires)
+		}
+		if _, err := path.Match(w.Resource, ""); err != nil {
+			return nil, fmt.Errorf("%s: waiver %d has an invalid resource pattern %q", filename, i+1, w.Resource)
+		}
+	}
+	return f.Waivers, nil
+}
+
+/*
+Expired returns true if the waiver is no longer valid at the given time.  A waiver is valid
+up to the end of the day it expires on.
+*/
+func (w Waiver) Expired(now time.Time) bool {
+	expires, err := time.Parse(DateFormat, w.Expires)
+	if err != nil {
+		return true
+	}
+	return !now.Before(expires.AddDate(0, 0, 1))
+}
+
+// matches reports whether the waiver covers a resource, by its ID or its name
+func (w Waiver) matches(r findings.Resource) bool {
+	if w.Resource == "" {
+		return true
+	}
+	if ok, _ := path.Match(w.Resource, r.ID); ok {
+		return true
+	}
+	ok, _ := path.Match(w.Resource, r.Name)
+	return r.Name != "" && ok
+}
+
+func (w Waiver) String() string {
+	return fmt.Sprintf("Accepted by %s until %s: %s", w.Approver, w.Expires, w.Justification)
+}
+
+/*
+Apply marks the open results covered by an unexpired waiver as Accepted Risk.  A finding
+is only accepted when every open resource in it is covered; anything left uncovered keeps
+it open.  Expired waivers are noted on the finding but otherwise ignored, so the finding
+shows as open again.
+*/
+func Apply(checks findings.Checks, waivers []Waiver, now time.Time) findings.Checks {
+	for name, f := range checks {
+		for _, w := range waivers {
+			if w.Check != f.ID() {
+				continue
+			}
+			if f.Notes == nil {
+				f.Notes = make(map[string]string)
+			}
+			if w.Expired(now) {
+				f.Notes["Waiver"] = fmt.Sprintf("Waiver approved by %s expired on %s", w.Approver, w.Expires)
+				continue
+			}
+			waived := false
+			for i := range f.Resources {
+				if f.Resources[i].Open == findings.FindingOpen && w.matches(f.Resources[i]) {
+					f.Resources[i].Open = findings.FindingAccepted
+					f.Resources[i].Evidence = fmt.Sprintf("%s (%s)", f.Resources[i].Evidence, w)
+					waived = true
+				}
+			}
+			if waived || w.Resource == "" {
+				f.Notes["Waiver"] = w.String()
+			}
+			if f.Status.Open == findings.FindingOpen && len(f.OpenResources()) == 0 && (waived || w.Resource == "") {
+				f.Status.Open = findings.FindingAccepted
+			}
+		}
+		checks[name] = f
+	}
+	return checks
+}